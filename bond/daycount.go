@@ -0,0 +1,124 @@
+// Package bond contains bond math: day count conventions, accrued interest
+package bond
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// DayCount is a day count convention
+type DayCount int
+
+const (
+	// ActAct counts accrued interest as part of the coupon value proportional
+	// to the actual days of coupon period (moex convention), year fractions
+	// are counted as actual days divided by the actual year days
+	ActAct DayCount = iota
+	// Act365 counts actual days, year is 365 days
+	Act365
+	// Thirty360 counts months as 30 days, year is 360 days (30E/360)
+	Thirty360
+)
+
+var dayCountNames = map[DayCount]string{
+	ActAct:    "act/act",
+	Act365:    "act/365",
+	Thirty360: "30/360",
+}
+
+func (d DayCount) String() string {
+	return dayCountNames[d]
+}
+
+// ParseDayCount parses day count convention name (`act/act', `act/365', `30/360')
+func ParseDayCount(s string) (DayCount, error) {
+	for d, name := range dayCountNames {
+		if name == s {
+			return d, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown day count convention `%v'", s)
+}
+
+// Days returns number of calendar days between dates
+func Days(start, end time.Time) float64 {
+	return math.Round(end.Sub(start).Hours() / 24)
+}
+
+func days360(start, end time.Time) float64 {
+	y1, m1, d1 := start.Date()
+	y2, m2, d2 := end.Date()
+
+	if d1 == 31 {
+		d1 = 30
+	}
+	if d2 == 31 {
+		d2 = 30
+	}
+
+	return float64(360*(y2-y1) + 30*(int(m2)-int(m1)) + (d2 - d1))
+}
+
+// YearFraction returns part of the year between dates
+func (d DayCount) YearFraction(start, end time.Time) float64 {
+	switch d {
+	case Act365:
+		return Days(start, end) / 365.0
+	case Thirty360:
+		return days360(start, end) / 360.0
+	}
+
+	if end.Before(start) {
+		return -d.YearFraction(end, start)
+	}
+
+	// split the interval by years, each part is divided by its year length
+	var result float64
+	for start.Year() < end.Year() {
+		var nextYear = time.Date(start.Year()+1, 1, 1, 0, 0, 0, 0, start.Location())
+		var yearDays = Days(time.Date(start.Year(), 1, 1, 0, 0, 0, 0, start.Location()), nextYear)
+
+		result += Days(start, nextYear) / yearDays
+		start = nextYear
+	}
+
+	var yearDays = Days(time.Date(start.Year(), 1, 1, 0, 0, 0, 0, start.Location()), time.Date(start.Year()+1, 1, 1, 0, 0, 0, 0, start.Location()))
+	return result + Days(start, end)/yearDays
+}
+
+// Coupon describes current coupon period
+type Coupon struct {
+	Start, End time.Time
+
+	Nominal float64
+	Percent float64 // annual coupon percent
+	Value   float64 // coupon payment, used by act/act convention
+}
+
+// AccruedInterest returns coupon interest accrued at `date' (usually it is a settlement date)
+func (d DayCount) AccruedInterest(c Coupon, date time.Time) float64 {
+	if !date.After(c.Start) {
+		return 0
+	}
+	if date.After(c.End) {
+		date = c.End
+	}
+
+	if d == ActAct {
+		var period = Days(c.Start, c.End)
+		if period <= 0 {
+			return 0
+		}
+
+		var value = c.Value
+		if value == 0 {
+			value = c.Nominal * c.Percent / 100.0 * period / 365.0
+		}
+
+		return value * Days(c.Start, date) / period
+	}
+
+	return c.Nominal * c.Percent / 100.0 * d.YearFraction(c.Start, date)
+}
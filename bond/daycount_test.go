@@ -0,0 +1,88 @@
+package bond
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", s, time.FixedZone("MSK", 3*60*60))
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func TestDays(t *testing.T) {
+	if d := Days(date("2024-01-10"), date("2025-01-10")); d != 366 {
+		t.Errorf("leap year contains %v days", d)
+	}
+	if d := Days(date("2024-10-10"), date("2024-10-01")); d != -9 {
+		t.Errorf("backward interval contains %v days", d)
+	}
+
+	// moscow used dst till 2011, days must be whole anyway
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skipf("tz database is missing: %v", err)
+	}
+	if d := Days(time.Date(2008, 3, 1, 0, 0, 0, 0, loc), time.Date(2008, 10, 30, 0, 0, 0, 0, loc)); d != 243 {
+		t.Errorf("dst interval contains %v days", d)
+	}
+}
+
+func TestYearFraction(t *testing.T) {
+	for _, tt := range []struct {
+		dayCount   DayCount
+		start, end string
+		fraction   float64
+	}{
+		{ActAct, "2024-01-01", "2025-01-01", 1},
+		{ActAct, "2023-07-01", "2024-07-01", 184.0/365 + 182.0/366},
+		{ActAct, "2024-07-01", "2023-07-01", -(184.0/365 + 182.0/366)},
+		{Act365, "2024-01-01", "2025-01-01", 366.0 / 365},
+		{Act365, "2024-10-10", "2025-04-08", 180.0 / 365},
+		{Thirty360, "2024-01-31", "2024-03-31", 60.0 / 360},
+		{Thirty360, "2024-02-29", "2025-02-28", 359.0 / 360},
+	} {
+		if f := tt.dayCount.YearFraction(date(tt.start), date(tt.end)); math.Abs(f-tt.fraction) > 1e-12 {
+			t.Errorf("%v %v .. %v: fraction is %v, expected %v", tt.dayCount, tt.start, tt.end, f, tt.fraction)
+		}
+	}
+}
+
+func TestAccruedInterest(t *testing.T) {
+	// semiannual ofz coupon: 8.15% of 1000, 40.64 rub for 182 days
+	var c = Coupon{Start: date("2024-08-07"), End: date("2025-02-05"), Nominal: 1000, Percent: 8.15, Value: 40.64}
+
+	for _, tt := range []struct {
+		dayCount DayCount
+		date     string
+		accrued  float64
+	}{
+		{ActAct, "2024-08-07", 0},
+		{ActAct, "2024-10-10", 40.64 * 64 / 182},
+		{ActAct, "2025-02-05", 40.64},
+		{ActAct, "2025-03-01", 40.64},
+		{Act365, "2024-10-10", 1000 * 0.0815 * 64 / 365},
+		{Thirty360, "2024-10-10", 1000 * 0.0815 * 63 / 360},
+	} {
+		if a := tt.dayCount.AccruedInterest(c, date(tt.date)); math.Abs(a-tt.accrued) > 1e-9 {
+			t.Errorf("%v at %v: accrued interest is %v, expected %v", tt.dayCount, tt.date, a, tt.accrued)
+		}
+	}
+}
+
+func TestParseDayCount(t *testing.T) {
+	for _, d := range []DayCount{ActAct, Act365, Thirty360} {
+		if parsed, err := ParseDayCount(d.String()); err != nil || parsed != d {
+			t.Errorf("`%v' is parsed as `%v' (%v)", d, parsed, err)
+		}
+	}
+
+	if _, err := ParseDayCount("act/360"); err == nil {
+		t.Errorf("unknown convention is parsed")
+	}
+}
@@ -0,0 +1,68 @@
+package bond
+
+import (
+	"sort"
+	"time"
+)
+
+// Schedule contains bond payments, coupons since `Reset' are floating (unknown),
+// zero reset means that all the coupons are known
+type Schedule struct {
+	Coupons       []CashFlow // unknown ones are estimated, e.g. by the last known coupon
+	Amortizations []CashFlow // nominal payments including redemption
+	Reset         time.Time
+}
+
+// Flows returns all the payments sorted by date
+func (s *Schedule) Flows() []CashFlow {
+	var result = append(append([]CashFlow(nil), s.Coupons...), s.Amortizations...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+
+	return result
+}
+
+// RateFlows returns payments defining price sensitivity to rates: floating coupons
+// follow market rates, so the rest nominal is expected to be paid at the reset
+func (s *Schedule) RateFlows() []CashFlow {
+	if s.Reset.IsZero() {
+		return s.Flows()
+	}
+
+	var result []CashFlow
+	for _, c := range s.Coupons {
+		if !c.Date.After(s.Reset) {
+			result = append(result, c)
+		}
+	}
+
+	var left float64
+	for _, a := range s.Amortizations {
+		if a.Date.Before(s.Reset) {
+			result = append(result, a)
+		} else {
+			left += a.Value
+		}
+	}
+	if left > 0 {
+		result = append(result, CashFlow{Date: s.Reset, Value: left})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+
+	return result
+}
+
+// Risk returns yield to maturity of the payments bought at `price' (dirty)
+// and duration and convexity of the rate flows at this yield
+func (s *Schedule) Risk(settlement time.Time, price float64) (float64, Risk, error) {
+	ytm, err := YieldToMaturity(s.Flows(), settlement, price)
+	if err != nil {
+		return 0, Risk{}, err
+	}
+
+	return ytm, YieldRisk(s.RateFlows(), settlement, ytm), nil
+}
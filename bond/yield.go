@@ -0,0 +1,103 @@
+package bond
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// CashFlow is a bond payment (coupon, amortization or redemption)
+type CashFlow struct {
+	Date  time.Time
+	Value float64
+}
+
+// years returns time to the payment in years (act/365), it's used for discounting
+func years(settlement, date time.Time) float64 {
+	return Days(settlement, date) / 365.0
+}
+
+// PresentValue returns value of the payments after `settlement' discounted
+// by effective annual yield `ytm' (fraction)
+func PresentValue(flows []CashFlow, settlement time.Time, ytm float64) float64 {
+	var result float64
+	for _, f := range flows {
+		if !f.Date.After(settlement) {
+			continue
+		}
+
+		result += f.Value / math.Pow(1+ytm, years(settlement, f.Date))
+	}
+
+	return result
+}
+
+// YieldToMaturity returns effective annual yield (fraction, moex convention)
+// of the payments bought at `price' (dirty, with accrued interest)
+func YieldToMaturity(flows []CashFlow, settlement time.Time, price float64) (float64, error) {
+	if price <= 0 {
+		return 0, fmt.Errorf("price must be positive")
+	}
+
+	var lo, hi = -0.99, 1.0
+	for PresentValue(flows, settlement, hi) > price {
+		if hi > 1e3 {
+			return 0, fmt.Errorf("yield is too high")
+		}
+
+		hi *= 2
+	}
+	if PresentValue(flows, settlement, lo) < price {
+		return 0, fmt.Errorf("payments are less than price")
+	}
+
+	// present value decreases with yield growth
+	for i := 0; i < 200 && hi-lo > 1e-12; i++ {
+		var mid = (lo + hi) / 2
+		if PresentValue(flows, settlement, mid) > price {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return (lo + hi) / 2, nil
+}
+
+// Risk contains bond price sensitivity to yield changes
+type Risk struct {
+	Macaulay  float64 // duration in years
+	Modified  float64 // price change (fraction) per yield change
+	Convexity float64
+}
+
+// YieldRisk returns duration and convexity of the payments at yield `ytm'
+func YieldRisk(flows []CashFlow, settlement time.Time, ytm float64) Risk {
+	var pv, weighted, convexity float64
+	for _, f := range flows {
+		if !f.Date.After(settlement) {
+			continue
+		}
+
+		var t = years(settlement, f.Date)
+		var v = f.Value / math.Pow(1+ytm, t)
+
+		pv += v
+		weighted += t * v
+		convexity += t * (t + 1) * v
+	}
+	if pv == 0 {
+		return Risk{}
+	}
+
+	var r = Risk{Macaulay: weighted / pv}
+	r.Modified = r.Macaulay / (1 + ytm)
+	r.Convexity = convexity / pv / math.Pow(1+ytm, 2)
+
+	return r
+}
+
+// PriceChange returns relative price change (fraction) for yield change `shift' (fraction)
+func (r Risk) PriceChange(shift float64) float64 {
+	return -r.Modified*shift + r.Convexity*shift*shift/2
+}
@@ -0,0 +1,91 @@
+package bond
+
+import (
+	"math"
+	"testing"
+)
+
+func TestYieldRisk(t *testing.T) {
+	var settlement = date("2024-01-10")
+	var after = func(days int, value float64) CashFlow {
+		return CashFlow{Date: settlement.AddDate(0, 0, days), Value: value}
+	}
+
+	for _, tt := range []struct {
+		name  string
+		flows []CashFlow
+		price float64
+		ytm   float64
+		risk  Risk
+	}{
+		{
+			name:  "zero coupon",
+			flows: []CashFlow{after(730, 1000)},
+			price: 1000 / 1.21,
+			ytm:   0.1,
+			risk:  Risk{Macaulay: 2, Modified: 2 / 1.1, Convexity: 6 / 1.21},
+		},
+		{
+			name:  "annual coupon at par",
+			flows: []CashFlow{after(-10, 100), after(365, 100), after(730, 100), after(1095, 1100)},
+			price: 1000,
+			ytm:   0.1,
+			// textbook 3 years 10% bond: sum(t * pv) / price, sum(t * (t + 1) * pv) / price / 1.1^2
+			risk: Risk{Macaulay: 2.7355371900826446, Modified: 2.7355371900826446 / 1.1, Convexity: 8.756232497780205},
+		},
+	} {
+		ytm, err := YieldToMaturity(tt.flows, settlement, tt.price)
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if math.Abs(ytm-tt.ytm) > 1e-9 {
+			t.Errorf("%v: ytm is %v, expected %v", tt.name, ytm, tt.ytm)
+		}
+
+		var r = YieldRisk(tt.flows, settlement, ytm)
+		if math.Abs(r.Macaulay-tt.risk.Macaulay) > 1e-6 || math.Abs(r.Modified-tt.risk.Modified) > 1e-6 || math.Abs(r.Convexity-tt.risk.Convexity) > 1e-6 {
+			t.Errorf("%v: risk is %+v, expected %+v", tt.name, r, tt.risk)
+		}
+	}
+}
+
+func TestYieldToMaturityOFZ(t *testing.T) {
+	// ofz 26207 (8.15%, 40.64 rub semiannual coupon) bought at 95% with accrued interest
+	var settlement = date("2024-10-10")
+	var flows = []CashFlow{
+		{Date: date("2025-02-05"), Value: 40.64},
+		{Date: date("2025-08-06"), Value: 40.64},
+		{Date: date("2026-02-04"), Value: 40.64},
+		{Date: date("2026-08-05"), Value: 40.64},
+		{Date: date("2027-02-03"), Value: 40.64 + 1000},
+	}
+	var price = 950 + ActAct.AccruedInterest(Coupon{Start: date("2024-08-07"), End: date("2025-02-05"), Value: 40.64}, settlement)
+
+	ytm, err := YieldToMaturity(flows, settlement, price)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pv := PresentValue(flows, settlement, ytm); math.Abs(pv-price) > 1e-6 {
+		t.Errorf("present value at ytm %v is %v, expected price %v", ytm, pv, price)
+	}
+	// discount bond yields more than its coupon yield (8.15 / 95 = 8.58%)
+	if ytm < 0.1 || ytm > 0.11 {
+		t.Errorf("ytm is %v, expected 10..11%%", ytm)
+	}
+
+	var r = YieldRisk(flows, settlement, ytm)
+	if maturity := years(settlement, date("2027-02-03")); r.Macaulay <= 2 || r.Macaulay >= maturity {
+		t.Errorf("duration is %v, expected 2 .. %v years", r.Macaulay, maturity)
+	}
+	if math.Abs(r.Modified-r.Macaulay/(1+ytm)) > 1e-12 {
+		t.Errorf("modified duration is %v, expected %v", r.Modified, r.Macaulay/(1+ytm))
+	}
+	if change := r.PriceChange(0.01); change <= -r.Modified/100 || change >= -r.Modified/100+0.001 {
+		t.Errorf("price change for +1%% is %v, expected a bit more than %v", change, -r.Modified/100)
+	}
+
+	if _, err = YieldToMaturity(flows, settlement, 0); err == nil {
+		t.Errorf("zero price is accepted")
+	}
+}
@@ -0,0 +1,171 @@
+// Package cbr downloads official bank of russia exchange rates and metal prices
+// (https://www.cbr.ru/development/SXML/)
+package cbr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetalCodes maps metals to cbr codes, their prices are per gram
+var MetalCodes = map[string]string{
+	"GLD": "1",
+	"SLV": "2",
+	"PLT": "3",
+	"PLD": "4",
+}
+
+// cbr uses `dd.mm.yyyy' dates
+const dateFormat = "02.01.2006"
+
+// DownloadRates returns rub rates of all currencies set for `date'
+func DownloadRates(date time.Time) (map[string]float64, error) {
+	var response struct {
+		Valutes []struct {
+			CharCode string `xml:"CharCode"`
+			Nominal  string `xml:"Nominal"`
+			Value    string `xml:"Value"`
+		} `xml:"Valute"`
+	}
+
+	url := fmt.Sprintf("https://www.cbr.ru/scripts/XML_daily.asp?date_req=%v", date.Format(dateFormat))
+	if err := get(url, &response); err != nil {
+		return nil, err
+	}
+
+	var result = make(map[string]float64)
+	for _, v := range response.Valutes {
+		nominal, err := parseNumber(v.Nominal)
+		if err != nil || nominal == 0 {
+			return nil, fmt.Errorf("invalid `%v' nominal `%v'", v.CharCode, v.Nominal)
+		}
+
+		value, err := parseNumber(v.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid `%v' rate `%v'", v.CharCode, v.Value)
+		}
+
+		result[v.CharCode] = value / nominal
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("rates not found in `%v' response", url)
+	}
+
+	return result, nil
+}
+
+// DownloadMetalPrice returns the last metal (see MetalCodes) price per gram set not later than `date'
+func DownloadMetalPrice(metal string, date time.Time) (float64, error) {
+	code, ok := MetalCodes[metal]
+	if !ok {
+		return 0, fmt.Errorf("unknown metal `%v'", metal)
+	}
+
+	var response struct {
+		Records []struct {
+			Code string `xml:"Code,attr"`
+			Buy  string `xml:"Buy"`
+		} `xml:"Record"`
+	}
+
+	// prices aren't set at weekends and holidays
+	url := fmt.Sprintf("https://www.cbr.ru/scripts/xml_metall.asp?date_req1=%v&date_req2=%v",
+		date.AddDate(0, 0, -14).Format(dateFormat), date.Format(dateFormat))
+	if err := get(url, &response); err != nil {
+		return 0, err
+	}
+
+	var price float64
+	for _, r := range response.Records {
+		if r.Code != code {
+			continue
+		}
+
+		v, err := parseNumber(r.Buy)
+		if err != nil {
+			return 0, fmt.Errorf("invalid `%v' price `%v'", metal, r.Buy)
+		}
+
+		price = v
+	}
+	if price == 0 {
+		return 0, fmt.Errorf("`%v' price not found in `%v' response", metal, url)
+	}
+
+	return price, nil
+}
+
+func get(url string, v interface{}) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return fmt.Errorf("GET failed: %v", err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("body read failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET failed: %v", resp.Status)
+	}
+
+	var dec = xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if !strings.EqualFold(charset, "windows-1251") {
+			return nil, fmt.Errorf("unsupported charset `%v'", charset)
+		}
+
+		data, err := ioutil.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.NewReader(decodeWindows1251(data)), nil
+	}
+	if err = dec.Decode(v); err != nil {
+		return fmt.Errorf("decode `%v' failed: %v", url, err)
+	}
+
+	return nil
+}
+
+// windows1251 contains unicode symbols of 0x80..0xbf bytes, 0xc0..0xff are `А'..`я'
+var windows1251 = [64]rune{
+	'\u0402', '\u0403', '\u201a', '\u0453', '\u201e', '\u2026', '\u2020', '\u2021',
+	'\u20ac', '\u2030', '\u0409', '\u2039', '\u040a', '\u040c', '\u040b', '\u040f',
+	'\u0452', '\u2018', '\u2019', '\u201c', '\u201d', '\u2022', '\u2013', '\u2014',
+	'\ufffd', '\u2122', '\u0459', '\u203a', '\u045a', '\u045c', '\u045b', '\u045f',
+	'\u00a0', '\u040e', '\u045e', '\u0408', '\u00a4', '\u0490', '\u00a6', '\u00a7',
+	'\u0401', '\u00a9', '\u0404', '\u00ab', '\u00ac', '\u00ad', '\u00ae', '\u0407',
+	'\u00b0', '\u00b1', '\u0406', '\u0456', '\u0491', '\u00b5', '\u00b6', '\u00b7',
+	'\u0451', '\u2116', '\u0454', '\u00bb', '\u0458', '\u0405', '\u0455', '\u0457',
+}
+
+// decodeWindows1251 converts windows-1251 text (cbr responses encoding) into utf-8
+func decodeWindows1251(data []byte) string {
+	var b strings.Builder
+	for _, c := range data {
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+		case c < 0xc0:
+			b.WriteRune(windows1251[c-0x80])
+		default:
+			b.WriteRune(rune(c-0xc0) + 'А')
+		}
+	}
+
+	return b.String()
+}
+
+func parseNumber(s string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", ".", 1), 64)
+}
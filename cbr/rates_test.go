@@ -0,0 +1,49 @@
+package cbr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecodeWindows1251(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"USD", "USD"},
+		{"\xc4\xee\xeb\xeb\xe0\xf0 \xd1\xd8\xc0", "Доллар США"},
+		{"\xa8\xb8\xb9\xa0\x96", "Ёё№\u00a0–"},
+	} {
+		if got := decodeWindows1251([]byte(tt.in)); got != tt.want {
+			t.Errorf("decodeWindows1251(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGetWindows1251(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<?xml version=\x221.0\x22 encoding=\x22windows-1251\x22?><ValCurs Date=\x2217.10.2026\x22 name=\x22Foreign Currency Market\x22><Valute ID=\x22R01235\x22><NumCode>840</NumCode><CharCode>USD</CharCode><Nominal>1</Nominal><Name>\xc4\xee\xeb\xeb\xe0\xf0 \xd1\xd8\xc0</Name><Value>81,2345</Value></Valute><Valute ID=\x22R01375\x22><NumCode>156</NumCode><CharCode>CNY</CharCode><Nominal>10</Nominal><Name>\xca\xe8\xf2\xe0\xe9\xf1\xea\xe8\xe9 \xfe\xe0\xed\xfc</Name><Value>112,5000</Value></Valute></ValCurs>"))
+	}))
+	defer srv.Close()
+
+	var response struct {
+		Valutes []struct {
+			CharCode string `xml:"CharCode"`
+			Name     string `xml:"Name"`
+			Value    string `xml:"Value"`
+		} `xml:"Valute"`
+	}
+	if err := get(srv.URL, &response); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+
+	if len(response.Valutes) != 2 {
+		t.Fatalf("got %v valutes, want 2", len(response.Valutes))
+	}
+	if v := response.Valutes[0]; v.CharCode != "USD" || v.Name != "Доллар США" || v.Value != "81,2345" {
+		t.Errorf("unexpected valute %+v", v)
+	}
+	if v := response.Valutes[1]; v.Name != "Китайский юань" {
+		t.Errorf("unexpected valute %+v", v)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/spectrec/invest-tools/dividend"
+	"github.com/spectrec/invest-tools/moex"
+)
+
+var boardArg = flag.String("board", "TQBR", "moex board")
+var yearsArg = flag.Int("years", 5, "number of full years used for average yield, stability and growth")
+var sortArg = flag.String("sort", "trailing-yield", "sort by: trailing-yield, average-yield, stability, growth, no-cuts")
+var formatArg = flag.String("format", "text", "output format: text, json")
+var threadPoolSizeArg = flag.Int("thread-pool-size", 10, "max number of goroutines for downloading dividends")
+var issCacheArg = flag.String("iss-cache", "", "path to directory for iss responses snapshots (by default: disabled)")
+
+// Result contains share dividend stats
+type Result struct {
+	Ticker string  `json:"ticker"`
+	Name   string  `json:"name"`
+	Price  float64 `json:"price"`
+
+	dividend.Stats
+}
+
+func main() {
+	flag.Parse()
+
+	moex.CacheDir = *issCacheArg
+
+	shares, err := moex.DownloadShares(*boardArg)
+	if err != nil {
+		log.Fatalf("can't download shares: %v", err)
+	}
+
+	// all board shares are ranked when tickers are not specified
+	var tickers = flag.Args()
+	if len(tickers) == 0 {
+		for ticker := range shares {
+			tickers = append(tickers, ticker)
+		}
+	}
+
+	var today = moex.Today()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var results []*Result
+
+	var ch = make(chan string, *threadPoolSizeArg)
+	wg.Add(*threadPoolSizeArg)
+	for i := 0; i < *threadPoolSizeArg; i++ {
+		go func() {
+			defer wg.Done()
+
+			for ticker := range ch {
+				var share = shares[ticker]
+				if share == nil {
+					log.Printf("share `%v' isn't traded at `%v'", ticker, *boardArg)
+					continue
+				}
+
+				history, err := moex.DownloadDividends(ticker)
+				if err != nil {
+					log.Printf("can't download `%v' dividends: %v", ticker, err)
+					continue
+				}
+
+				var r = Result{Ticker: ticker, Name: share.Name, Price: share.Price}
+				r.Stats = dividend.Analyze(history, "RUB", share.Price, today, *yearsArg)
+
+				mu.Lock()
+				results = append(results, &r)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, ticker := range tickers {
+		ch <- ticker
+	}
+	close(ch)
+
+	wg.Wait()
+
+	if err = sortResults(results, *sortArg); err != nil {
+		log.Fatal(err)
+	}
+
+	switch *formatArg {
+	case "text":
+		for _, r := range results {
+			var last = "-"
+			if !r.LastPayment.IsZero() {
+				last = r.LastPayment.Format(moex.DateFormat)
+			}
+
+			fmt.Printf("ticker: %-8s price: %10.2f  trailing: %6.2f%%  average: %6.2f%%  stability: %5.1f%%  growth: %7.2f%%  no cuts: %2v years  last: %-10s  name: %s\n",
+				r.Ticker, r.Price, r.TrailingYield, r.AverageYield, r.Stability, r.GrowthRate, r.YearsWithoutCuts, last, r.Name)
+		}
+	case "json":
+		data, err := json.MarshalIndent(results, "", "\t")
+		if err != nil {
+			log.Fatalf("can't encode result: %v", err)
+		}
+
+		os.Stdout.Write(append(data, '\n'))
+	default:
+		log.Fatalf("unknown format `%v'", *formatArg)
+	}
+}
+
+func sortResults(results []*Result, by string) error {
+	var metric func(r *Result) float64
+	switch by {
+	case "trailing-yield":
+		metric = func(r *Result) float64 { return r.TrailingYield }
+	case "average-yield":
+		metric = func(r *Result) float64 { return r.AverageYield }
+	case "stability":
+		metric = func(r *Result) float64 { return r.Stability }
+	case "growth":
+		metric = func(r *Result) float64 { return r.GrowthRate }
+	case "no-cuts":
+		metric = func(r *Result) float64 { return float64(r.YearsWithoutCuts) }
+	default:
+		return fmt.Errorf("unknown sort `%v'", by)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if metric(results[i]) != metric(results[j]) {
+			return metric(results[i]) > metric(results[j])
+		}
+
+		return results[i].Ticker < results[j].Ticker
+	})
+
+	return nil
+}
@@ -18,7 +18,8 @@ type price struct {
 }
 
 var argStartDate = flag.String("start-date", "", "specify start date (dd.mm.yyyy)")
-var argEndDate = flag.String("end-date", "", "specify end date (dd.mm.yyyy)")
+var argEndDate = flag.String("end-date", "", "specify end date (dd.mm.yyyy, by default: `-as-of' date)")
+var argAsOf = flag.String("as-of", "", "calculate result as of date dd.mm.yyyy or yyyy-mm-dd (by default: today)")
 
 var argInitialSum = flag.Float64("initial-sum", 0, "specify initial invest sum")
 
@@ -55,12 +56,21 @@ func main() {
 		if err != nil {
 			log.Fatalf("bad `end-date' specified `%v': %v", *argEndDate, err)
 		}
+	} else if *argAsOf != "" {
+		// other tools take yyyy-mm-dd, so both formats are accepted
+		endDate, err = time.Parse("02.01.2006", *argAsOf)
+		if err != nil {
+			endDate, err = time.Parse("2006-01-02", *argAsOf)
+		}
+		if err != nil {
+			log.Fatalf("bad `as-of' specified `%v': %v", *argAsOf, err)
+		}
 	} else {
 		endDate = time.Now()
 	}
 
 	if startDate.After(endDate) {
-		log.Fatal("`-start-date' (%v) must be lower than `-end-date' (%v)", startDate, endDate)
+		log.Fatalf("`-start-date' (%v) must be lower than `-end-date' (%v)", startDate, endDate)
 	}
 
 	// keep only interesting for us records
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+var backtestFromArg = flag.String("backtest-from", "", "run backtest from date yyyy-mm-dd till `-as-of' date instead of screening (by default: disabled)")
+var backtestTopArg = flag.Int("backtest-top", 10, "number of best bonds bought at each monthly rebalance")
+var backtestBenchmarkArg = flag.String("backtest-benchmark", "RGBITR", "benchmark index (by default: ofz total return index)")
+var backtestDefaultPriceArg = flag.Float64("backtest-default-price", 50, "clean price percent, bond with lower price is considered to be defaulted")
+
+// quote is a security market state at some date
+type quote struct {
+	CleanPricePercent float64
+	AccruedInterest   float64
+	Nominal           float64
+}
+
+func (q quote) dirtyPrice() float64 {
+	return q.Nominal*q.CleanPricePercent/100.0 + q.AccruedInterest
+}
+
+type holding struct {
+	sec   *Security
+	count float64 // bonds count, partial bonds are allowed to keep weights equal
+	quote quote   // the last observed quote, it's used while the bond isn't traded
+}
+
+// backtestPeriod describes holding of the selected bonds between two rebalances
+type backtestPeriod struct {
+	From, Till time.Time
+
+	Bonds      int
+	StartValue float64
+	Value      float64 // at the end of the period
+	Coupons    float64 // after taxes
+	Redemption float64
+	Commission float64
+
+	Return          float64 // percent
+	BenchmarkReturn float64 // percent
+
+	Events []string
+}
+
+// backtest replays screening at monthly rebalance dates using iss history, selected bonds
+// are bought with equal weights and held till the next rebalance
+func backtest(sc *Screen, till time.Time) error {
+	from, err := moex.ParseDate(*backtestFromArg)
+	if err != nil {
+		return fmt.Errorf("can't parse `-backtest-from' date: %v", err)
+	}
+	if !from.Before(till) {
+		return fmt.Errorf("`-backtest-from' date must be lower than `-as-of' date")
+	}
+
+	var dates []time.Time
+	for i := 0; ; i++ {
+		var date = from.AddDate(0, i, 0)
+		if !date.Before(till) {
+			break
+		}
+
+		dates = append(dates, date)
+	}
+	dates = append(dates, till)
+
+	benchmark, err := downloadIndexHistory(*backtestBenchmarkArg, from.AddDate(0, 0, -10), till)
+	if err != nil {
+		return fmt.Errorf("can't download benchmark `%v' history: %v", *backtestBenchmarkArg, err)
+	}
+
+	var value, cash = 100.0, 0.0
+	var holdings []*holding
+	var periods []*backtestPeriod
+	for i, date := range dates {
+		date, securities, err := marketSnapshot(sc.Calendar, date)
+		if err != nil {
+			return err
+		}
+
+		var quotes = make(map[string]quote)
+		for secid, s := range securities {
+			if s.CleanPricePercent > 0 {
+				quotes[secid] = quote{CleanPricePercent: s.CleanPricePercent, AccruedInterest: s.Coupon.AccruedInterest, Nominal: s.Lot.Price}
+			}
+		}
+
+		if i > 0 {
+			var p = periods[len(periods)-1]
+			p.Till = date
+
+			p.Value, err = holdPeriod(p, holdings, cash, quotes)
+			if err != nil {
+				return err
+			}
+
+			p.Return = (p.Value/p.StartValue - 1) * 100.0
+			p.BenchmarkReturn = (benchmark.at(p.Till)/benchmark.at(p.From) - 1) * 100.0
+
+			value = p.Value
+		}
+		if i == len(dates)-1 {
+			break
+		}
+
+		result, err := sc.run(date, securities)
+		if err != nil {
+			return err
+		}
+
+		var selected = result.Bonds
+		if len(selected) > *backtestTopArg {
+			selected = selected[:*backtestTopArg]
+		}
+
+		var p = backtestPeriod{From: date, Bonds: len(selected), StartValue: value}
+		holdings, cash, p.Commission = rebalance(holdings, selected, quotes, value)
+
+		periods = append(periods, &p)
+	}
+
+	printBacktest(periods, benchmark)
+
+	return nil
+}
+
+// marketSnapshot returns securities traded at `date', when there are no trades
+// (holiday missing in calendar) previous trading days are used
+func marketSnapshot(calendar *moex.Calendar, date time.Time) (time.Time, map[string]*Security, error) {
+	var err error
+	for try := 0; try < 10; try++ {
+		for !calendar.IsTradingDay(date) {
+			date = date.AddDate(0, 0, -1)
+		}
+
+		var securities map[string]*Security
+		securities, err = downloadSecuritiesHistory(date)
+		if err == nil {
+			return date, securities, nil
+		}
+
+		log.Printf("can't download market snapshot at `%v': %v", date.Format(moex.DateFormat), err)
+		date = date.AddDate(0, 0, -1)
+	}
+
+	return date, nil, fmt.Errorf("can't find trading day for snapshot: %v", err)
+}
+
+// rebalance sells all current holdings and buys `selected' bonds with equal weights,
+// commission is taken only for the changed part of positions, returns new holdings,
+// not invested cash and commission
+func rebalance(holdings []*holding, selected []*Security, quotes map[string]quote, value float64) ([]*holding, float64, float64) {
+	var current = make(map[string]float64)
+	for _, h := range holdings {
+		q, ok := quotes[h.sec.ID]
+		if !ok {
+			q = h.quote
+		}
+
+		current[h.sec.ID] += h.count * q.dirtyPrice()
+	}
+
+	var turnover float64
+	var target = make(map[string]float64)
+	for _, s := range selected {
+		target[s.ID] = value / float64(len(selected))
+	}
+	for id, v := range target {
+		turnover += math.Abs(v - current[id])
+	}
+	for id, v := range current {
+		if _, ok := target[id]; !ok {
+			turnover += v
+		}
+	}
+
+	var commission = turnover * *commissionPercentArg / 100.0
+
+	var cash = value - commission
+	if len(selected) == 0 {
+		return nil, cash, commission
+	}
+
+	var weight = cash / float64(len(selected))
+
+	var result []*holding
+	for _, s := range selected {
+		var q = quotes[s.ID]
+		var price = q.dirtyPrice()
+		if price <= 0 {
+			continue
+		}
+
+		result = append(result, &holding{sec: s, count: weight / price, quote: q})
+		cash -= weight
+	}
+
+	return result, cash, commission
+}
+
+// holdPeriod returns holdings value at the end of the period including received payments
+// and not invested cash; bond without trades in the period is valued by its previous quote,
+// default is detected only by observed price
+func holdPeriod(p *backtestPeriod, holdings []*holding, cash float64, quotes map[string]quote) (float64, error) {
+	var value = cash
+
+	for _, h := range holdings {
+		var s = h.sec
+		var paidTill = p.Till
+
+		q, traded := quotes[s.ID]
+		if !traded && s.MaturityDate.After(p.Till) {
+			var early bool
+			for _, offer := range s.offers {
+				if offer.After(p.From) && !offer.After(p.Till) {
+					early = true
+					break
+				}
+			}
+
+			if early {
+				var nominal = s.Nominal
+				for _, a := range s.amortizations {
+					if a.Date.After(p.From) && !a.Date.After(p.Till) {
+						nominal -= a.Value
+					}
+				}
+
+				p.Redemption += h.count * nominal
+				p.Events = append(p.Events, "early redemption: "+s.ShortName)
+			} else {
+				last, lastDate, found, err := lastQuote(s.ID, p.From, p.Till)
+				if err != nil {
+					return 0, err
+				}
+
+				if !found {
+					// illiquid bond, its previous quote is carried forward
+					q, traded = h.quote, true
+					p.Events = append(p.Events, "no trades: "+s.ShortName)
+				} else if last.CleanPricePercent >= *backtestDefaultPriceArg {
+					// not traded at the rebalance date, use the last trade
+					q, traded = last, true
+				} else {
+					// the bond is delisted or its price collapsed, coupons after the last trade aren't paid
+					value += h.count * last.dirtyPrice()
+					paidTill = lastDate
+					p.Events = append(p.Events, "default: "+s.ShortName)
+				}
+			}
+		}
+
+		if traded {
+			if q.CleanPricePercent < *backtestDefaultPriceArg {
+				p.Events = append(p.Events, "default: "+s.ShortName)
+			}
+
+			value += h.count * q.dirtyPrice()
+			h.quote = q
+		}
+
+		for _, c := range s.coupons {
+			if c.Date.After(p.From) && !c.Date.After(paidTill) {
+				p.Coupons += h.count * c.Value * (1 - *taxPercentArg)
+			}
+		}
+		for _, a := range s.amortizations {
+			if a.Date.After(p.From) && !a.Date.After(paidTill) {
+				p.Redemption += h.count * a.Value
+			}
+		}
+		if len(s.amortizations) == 0 && !s.MaturityDate.After(p.Till) {
+			// schedule is unknown, expect redemption at maturity
+			p.Redemption += h.count * s.Nominal
+		}
+	}
+
+	return value + p.Coupons + p.Redemption, nil
+}
+
+// lastQuote returns the last known security quote in [from, till] interval,
+// false is returned if the security isn't traded there
+func lastQuote(secid string, from, till time.Time) (quote, time.Time, bool, error) {
+	url := fmt.Sprintf("https://iss.moex.com/iss/history/engines/stock/markets/bonds/securities/%v.json?iss.meta=off&from=%v&till=%v&history.columns=TRADEDATE,LEGALCLOSEPRICE,ACCINT,FACEVALUE",
+		secid, from.Format(moex.DateFormat), till.Format(moex.DateFormat))
+
+	rows, err := moex.GetHistory(url)
+	if err != nil {
+		return quote{}, from, false, fmt.Errorf("can't download `%v' history: %v", secid, err)
+	}
+
+	var result quote
+	var date = from
+	var found bool
+	for _, v := range rows {
+		if len(v) != 4 || v[1] == nil {
+			continue
+		}
+
+		d, err := moex.ParseDate(moex.String(v[0]))
+		if err != nil || d.Before(date) {
+			continue
+		}
+
+		date, found = d, true
+		result = quote{CleanPricePercent: moex.Float(v[1]), AccruedInterest: moex.Float(v[2]), Nominal: moex.Float(v[3])}
+	}
+
+	return result, date, found, nil
+}
+
+// indexHistory contains index close values sorted by date
+type indexHistory struct {
+	dates  []time.Time
+	values []float64
+}
+
+// at returns the last known value at `date'
+func (h *indexHistory) at(date time.Time) float64 {
+	i := sort.Search(len(h.dates), func(i int) bool { return h.dates[i].After(date) })
+	if i == 0 {
+		return math.NaN()
+	}
+
+	return h.values[i-1]
+}
+
+func downloadIndexHistory(index string, from, till time.Time) (*indexHistory, error) {
+	url := fmt.Sprintf("https://iss.moex.com/iss/history/engines/stock/markets/index/securities/%v.json?iss.meta=off&from=%v&till=%v&history.columns=TRADEDATE,CLOSE",
+		index, from.Format(moex.DateFormat), till.Format(moex.DateFormat))
+
+	rows, err := moex.GetHistory(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var result indexHistory
+	for _, v := range rows {
+		if len(v) != 2 || v[1] == nil {
+			continue
+		}
+
+		date, err := moex.ParseDate(moex.String(v[0]))
+		if err != nil {
+			return nil, fmt.Errorf("bad date `%v': %v", v[0], err)
+		}
+
+		result.dates = append(result.dates, date)
+		result.values = append(result.values, moex.Float(v[1]))
+	}
+	if len(result.dates) == 0 {
+		return nil, fmt.Errorf("there is no `%v' history", index)
+	}
+
+	return &result, nil
+}
+
+func annualize(percent float64, days float64) float64 {
+	return (math.Pow(1+percent/100.0, 365.0/days) - 1) * 100.0
+}
+
+func printBacktest(periods []*backtestPeriod, benchmark *indexHistory) {
+	if len(periods) == 0 {
+		return
+	}
+
+	var from, till = periods[0].From, periods[len(periods)-1].Till
+	var value = 100.0
+	var coupons, redemption, commission float64
+	var defaults, earlyRedemptions int
+
+	fmt.Printf("Backtest %v .. %v (top %v bonds, monthly rebalance):\n", from.Format(moex.DateFormat), till.Format(moex.DateFormat), *backtestTopArg)
+	for _, p := range periods {
+		fmt.Printf(" %v: bonds: %2v, value: %8.2f, return: %6.2f%%, %v: %6.2f%%",
+			p.From.Format(moex.DateFormat), p.Bonds, p.Value, p.Return, *backtestBenchmarkArg, p.BenchmarkReturn)
+		if len(p.Events) != 0 {
+			fmt.Printf(" [%v]", strings.Join(p.Events, ", "))
+		}
+		fmt.Println()
+
+		value = p.Value
+		coupons += p.Coupons
+		redemption += p.Redemption
+		commission += p.Commission
+
+		for _, e := range p.Events {
+			switch {
+			case strings.HasPrefix(e, "default"):
+				defaults++
+			case strings.HasPrefix(e, "early redemption"):
+				earlyRedemptions++
+			}
+		}
+	}
+
+	var days = till.Sub(from).Hours() / 24
+	var total = value - 100.0
+	var benchmarkTotal = (benchmark.at(till)/benchmark.at(from) - 1) * 100.0
+
+	fmt.Printf("\nResult stat:\n")
+	fmt.Printf(" Days:                  %v\n", days)
+	fmt.Printf(" Realised return:       %.2f%% (annualized: %.2f%%)\n", total, annualize(total, days))
+	fmt.Printf(" Benchmark return:      %.2f%% (annualized: %.2f%%)\n", benchmarkTotal, annualize(benchmarkTotal, days))
+	fmt.Printf(" Excess return:         %.2f%%\n", total-benchmarkTotal)
+	fmt.Printf(" Coupons (after taxes): %.2f\n", coupons)
+	fmt.Printf(" Redemptions:           %.2f\n", redemption)
+	fmt.Printf(" Commission:            %.2f\n", commission)
+	fmt.Printf(" Defaults:              %v\n", defaults)
+	fmt.Printf(" Early redemptions:     %v\n", earlyRedemptions)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spectrec/invest-tools/bond"
+	"github.com/spectrec/invest-tools/moex"
+)
+
+var defaultsRegistryArg = flag.String("defaults-registry", "defaults.registry", "path to file, contains known emitents defaults")
+var defaultsLookbackArg = flag.Int("defaults-lookback-years", 3, "defaults older than N years are ignored")
+var defaultsActionArg = flag.String("defaults-action", "warn", "what to do with bonds of emitents with detected missed payments: exclude, warn (registry defaults are always excluded)")
+var defaultsGraceDaysArg = flag.Int("defaults-grace-days", 5, "scheduled payment is considered missed if it's still unpaid after N trading days")
+
+// DefaultEvent describes emitent payment failure
+type DefaultEvent struct {
+	Date      time.Time `json:"date"`
+	Technical bool      `json:"technical"`
+	Detected  bool      `json:"detected"` // found using payments schedule, not the registry
+	Reason    string    `json:"reason"`
+}
+
+func (e *DefaultEvent) String() string {
+	var kind = "default"
+	if e.Technical {
+		kind = "technical default"
+	}
+
+	return fmt.Sprintf("%v at %v (%v)", kind, e.Date.Format(moex.DateFormat), e.Reason)
+}
+
+// loadDefaultsRegistry returns known defaults by emitent inn, file format:
+//
+//	inn yyyy-mm-dd default|technical [-> comment]
+func loadDefaultsRegistry(path string) (map[string][]*DefaultEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open file `%v': %v", path, err)
+	}
+	defer f.Close()
+
+	var result = make(map[string][]*DefaultEvent)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var comment = "registry"
+		if parts := strings.SplitN(line, " -> ", 2); len(parts) == 2 {
+			line, comment = parts[0], "registry: "+parts[1]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 || (fields[2] != "default" && fields[2] != "technical") {
+			return nil, fmt.Errorf("bad defaults registry format `%v' (expected: `inn yyyy-mm-dd default|technical [-> comment]')", line)
+		}
+
+		date, err := moex.ParseDate(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("bad date in `%v': %v", line, err)
+		}
+
+		result[fields[0]] = append(result[fields[0]], &DefaultEvent{Date: date, Technical: fields[2] == "technical", Reason: comment})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("defaults registry scan failed: %v", err)
+	}
+
+	return result, nil
+}
+
+// overdue checks whether payment scheduled at `date' is still expected after the grace period
+func overdue(date, today time.Time, calendar *moex.Calendar) bool {
+	return calendar.AddTradingDays(date, *defaultsGraceDaysArg).Before(today)
+}
+
+// nextCoupon returns the next coupon date known by the market: iss market data is used if it's
+// available, otherwise (history) it's derived from bondization: accrued interest of unpaid coupons
+// keeps growing, so the coupons covered by accrued interest are treated as not paid yet
+func (s *Security) nextCoupon(today time.Time) (time.Time, bool) {
+	if next, err := moex.ParseDate(s.Coupon.NextCouponDate); err == nil {
+		return next, true
+	}
+
+	var i = sort.Search(len(s.coupons), func(i int) bool {
+		return s.coupons[i].Date.After(today)
+	})
+	if i == 0 || i == len(s.coupons) {
+		return time.Time{}, false
+	}
+
+	var value = func(i int) float64 {
+		if v := s.coupons[i].Value; v > 0 {
+			return v
+		}
+
+		return s.Coupon.Value
+	}
+
+	var start, end = s.coupons[i-1].Date, s.coupons[i].Date
+	var expected = value(i) * bond.Days(start, today) / bond.Days(start, end)
+	for i > 0 && s.Coupon.AccruedInterest > expected+value(i-1)/2 {
+		i--
+		expected += value(i)
+	}
+
+	return s.coupons[i].Date, true
+}
+
+// detectDefault compares bondization coupons with the next coupon date known by the market:
+// coupon is unpaid if the next coupon is still the one scheduled before the grace period
+func (s *Security) detectDefault(today time.Time, calendar *moex.Calendar) *DefaultEvent {
+	next, ok := s.nextCoupon(today)
+	if !ok {
+		return nil
+	}
+
+	for _, c := range s.coupons {
+		if c.Date.Before(next) || !overdue(c.Date, today, calendar) {
+			continue
+		}
+
+		return &DefaultEvent{Date: c.Date, Technical: true, Detected: true, Reason: "coupon is unpaid: " + s.ISIN}
+	}
+
+	return nil
+}
+
+// isIndexed checks whether bond nominal is indexed (ОФЗ-ИН and similar),
+// so it doesn't match amortization schedule
+func (s *Security) isIndexed() bool {
+	return strings.HasPrefix(s.ISIN, "SU52") || strings.Contains(strings.ToUpper(s.SecName), "-ИН")
+}
+
+// detectMissedAmortization compares bondization amortizations with the current nominal
+func (s *Security) detectMissedAmortization(today time.Time, calendar *moex.Calendar) *DefaultEvent {
+	if len(s.amortizations) == 0 || s.isIndexed() {
+		return nil
+	}
+
+	// amortizations cover the whole nominal, so the rest ones must match the current nominal
+	var expected float64
+	var missed = today
+	for _, a := range s.amortizations {
+		if overdue(a.Date, today, calendar) {
+			missed = a.Date
+			continue
+		}
+
+		expected += a.Value
+	}
+
+	if expected > 0 && s.Nominal > expected+0.01 {
+		return &DefaultEvent{Date: missed, Technical: true, Detected: true,
+			Reason: fmt.Sprintf("nominal %.2f doesn't match amortization schedule %.2f: %v", s.Nominal, expected, s.ISIN)}
+	}
+
+	return nil
+}
+
+// recentDefaults returns emitent defaults, which are not older than `-defaults-lookback-years'
+func recentDefaults(events []*DefaultEvent, today time.Time) []*DefaultEvent {
+	var result []*DefaultEvent
+	for _, e := range events {
+		if e.Date.After(today.AddDate(-*defaultsLookbackArg, 0, 0)) && !e.Date.After(today) {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+// checkDefaults flags security when its emitent has recent defaults, it's rejected
+// if defaults are known from the registry or `-defaults-action' is exclude
+func (s *Security) checkDefaults(defaults map[string][]*DefaultEvent, today time.Time) {
+	if s.Emitent == nil || s.Rejection != nil {
+		return
+	}
+
+	events := recentDefaults(defaults[s.Emitent.INN], today)
+	if len(events) == 0 {
+		return
+	}
+
+	s.Defaults = events
+	for _, e := range events {
+		if !e.Detected || *defaultsActionArg == "exclude" {
+			s.reject(rejectDefault, e.String(), fmt.Sprintf("no defaults for %v years", *defaultsLookbackArg))
+			return
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+func date(s string) time.Time {
+	t, err := moex.ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func TestDetectDefault(t *testing.T) {
+	var coupons = []moex.Payment{
+		{Date: date("2024-01-10"), Value: 50},
+		{Date: date("2024-07-10"), Value: 50},
+		{Date: date("2025-01-10"), Value: 50},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		next     string  // iss market data next coupon, empty in history
+		accrued  float64 // at 2024-10-10
+		today    string
+		unpaid   bool
+		unpaidAt string
+	}{
+		{name: "market data, paid", next: "2025-01-10", accrued: 25, today: "2024-10-10"},
+		{name: "market data, unpaid", next: "2024-07-10", accrued: 75, today: "2024-10-10", unpaid: true, unpaidAt: "2024-07-10"},
+		{name: "market data, grace period", next: "2024-07-10", accrued: 50, today: "2024-07-12"},
+		{name: "history, paid", accrued: 25, today: "2024-10-10"},
+		{name: "history, unpaid", accrued: 75, today: "2024-10-10", unpaid: true, unpaidAt: "2024-07-10"},
+		{name: "history, two unpaid", accrued: 125, today: "2024-10-10", unpaid: true, unpaidAt: "2024-01-10"},
+	} {
+		var s = &Security{ISIN: "RU000TEST", coupons: coupons}
+		s.Coupon.NextCouponDate = tt.next
+		s.Coupon.AccruedInterest = tt.accrued
+
+		var e = s.detectDefault(date(tt.today), moex.NewCalendar())
+		if (e != nil) != tt.unpaid {
+			t.Errorf("%v: detected: %v, want: %v", tt.name, e, tt.unpaid)
+			continue
+		}
+		if e != nil && !e.Date.Equal(date(tt.unpaidAt)) {
+			t.Errorf("%v: unpaid coupon %v, want %v", tt.name, e.Date.Format(moex.DateFormat), tt.unpaidAt)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// EmitentGroup contains result bonds of the single emitent
+type EmitentGroup struct {
+	Key     string
+	Emitent *moex.Emitent
+	Comment string
+
+	Bonds  []*Security
+	Issues int // number of suitable issues, including ones dropped by `-best-per-emitent'
+
+	MinYield, MaxYield       float64
+	MinMaturity, MaxMaturity time.Time
+}
+
+func (g *EmitentGroup) String() string {
+	var b strings.Builder
+
+	var title = g.Key
+	if g.Emitent != nil {
+		title = fmt.Sprintf("%v (inn: %v)", g.Emitent.Title, g.Emitent.INN)
+	}
+
+	fmt.Fprintf(&b, "emitent: %v\n", title)
+	fmt.Fprintf(&b, "\tissues: %v (shown: %v)\n", g.Issues, len(g.Bonds))
+	fmt.Fprintf(&b, "\tyield to maturity: %.2f%% .. %.2f%%\n", g.MinYield, g.MaxYield)
+	fmt.Fprintf(&b, "\tmaturity date: %v .. %v", g.MinMaturity.Format(moex.DateFormat), g.MaxMaturity.Format(moex.DateFormat))
+	if g.Comment != "" {
+		fmt.Fprintf(&b, "\n\tcomment: %v", g.Comment)
+	}
+
+	return b.String()
+}
+
+func (g *EmitentGroup) add(s *Security, limit int) {
+	if limit <= 0 || len(g.Bonds) < limit {
+		g.Bonds = append(g.Bonds, s)
+	}
+
+	if g.Issues == 0 || s.YieldToMaturity < g.MinYield {
+		g.MinYield = s.YieldToMaturity
+	}
+	if g.Issues == 0 || s.YieldToMaturity > g.MaxYield {
+		g.MaxYield = s.YieldToMaturity
+	}
+	if g.Issues == 0 || s.MaturityDate.Before(g.MinMaturity) {
+		g.MinMaturity = s.MaturityDate
+	}
+	if g.Issues == 0 || s.MaturityDate.After(g.MaxMaturity) {
+		g.MaxMaturity = s.MaturityDate
+	}
+
+	g.Issues++
+}
+
+// bonds without known emitent are grouped by itself
+func emitentKey(s *Security) string {
+	if s.Emitent == nil || s.Emitent.INN == "" {
+		return "secid: " + s.ID
+	}
+
+	return s.Emitent.INN
+}
+
+// groupByEmitent groups sorted bonds by emitent inn, keeping the bonds order
+// (groups are ordered by the first bond), only first `limit' bonds are kept
+// in each group (0 - keep all), but totals are calculated using all of them
+func groupByEmitent(bonds []*Security, limit int) []*EmitentGroup {
+	var groups []*EmitentGroup
+	var key2group = make(map[string]*EmitentGroup)
+
+	for _, b := range bonds {
+		var key = emitentKey(b)
+
+		g := key2group[key]
+		if g == nil {
+			g = &EmitentGroup{Key: key, Emitent: b.Emitent, Comment: b.Comment}
+			key2group[key] = g
+
+			groups = append(groups, g)
+		}
+
+		g.add(b, limit)
+	}
+
+	return groups
+}
+
+// keepBestPerEmitent keeps only first `limit' bonds of each emitent (bonds must be sorted),
+// the others are appended into rejected list
+func keepBestPerEmitent(bonds, rejected []*Security, limit int) ([]*Security, []*Security) {
+	var result []*Security
+	var key2count = make(map[string]int)
+
+	for _, b := range bonds {
+		var key = emitentKey(b)
+
+		key2count[key]++
+		if key2count[key] > limit {
+			b.reject(rejectEmitentLimit, fmt.Sprintf("issue #%v", key2count[key]), fmt.Sprintf("%v per emitent", limit))
+			rejected = append(rejected, b)
+
+			continue
+		}
+
+		result = append(result, b)
+	}
+
+	return result, rejected
+}
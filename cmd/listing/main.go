@@ -9,24 +9,29 @@ import (
 	"io/ioutil"
 	"log"
 	"math"
-	"net/http"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
-	"sync"
 	"time"
+
+	"github.com/spectrec/invest-tools/bond"
+	"github.com/spectrec/invest-tools/moex"
 )
 
 // moex references:
 // - http://iss.moex.com/iss/reference/ - api methods
 // - https://www.moex.com/a2193 - common api description
 // - https://iss.moex.com/iss/engines/stock/markets/bonds/securities/columns.json columns description
-// - http://iss.moex.com/iss/securities/TATN/dividends.json?iss.json=extended - dividend history (for future)
+// - http://iss.moex.com/iss/securities/TATN/dividends.json?iss.json=extended - dividend history (see cmd/dividends)
 
 var anyCouponTypesArg = flag.Bool("any-coupon-type", false, "show bonds with all coupon types (by default: fixed only)")
 var anyRedemptionTypesArg = flag.Bool("any-redemption-type", false, "show bonds with all redemption types (by default: non amortization only)")
 var sortByCurrentCouponYieldArg = flag.Bool("sort-by-current-coupon-yield", false, "sort result using current coupon yield instead of yield to maturity")
 
+var groupByEmitentArg = flag.Bool("group-by-emitent", false, "group result by emitent (inn), groups are ordered by the best bond")
+var bestPerEmitentArg = flag.Int("best-per-emitent", 0, "keep only N best bonds per emitent (by default: keep all)")
+
 var minCouponPercentArg = flag.Float64("min-coupon-percent", 1.0, "minimum allowed coupon percent (skip others)")
 var minCleanPricePercentArg = flag.Float64("min-clean-price-percent", 90.0, "minimum allowed clean percent (skip others)")
 
@@ -41,70 +46,31 @@ var minEurSuitablePercentArg = flag.Float64("min-eur-yield", 4, "min euro yield
 var minMaturityDateArg = flag.String("min-maturity-date", "", "min maturity date yyyy-mm-dd (by default: today + 1 years)")
 var maxMaturityDateArg = flag.String("max-maturity-date", "", "max maturity date yyyy-mm-dd (by default: today + 3 years)")
 
+var budgetArg = flag.Float64("budget", 0, "investment budget in rubles, used for position sizing (by default: disabled)")
+var maxPositionPercentArg = flag.Float64("max-position-percent", 100, "max position size in percent of budget (skip bonds with more expensive lot)")
+var commissionPercentArg = flag.Float64("commission-percent", 0.05, "broker commission percent (used for position sizing)")
+
+var dayCountArg = flag.String("day-count", "act/act", "day count convention for accrued interest: act/act (moex), act/365, 30/360")
+var settlementDaysArg = flag.Int("settlement-days", 1, "settlement lag in trading days (T+N)")
+var calendarArg = flag.String("calendar", "", "path to file, contains exchange holidays (by default: builtin approximation is used)")
+
+var asOfArg = flag.String("as-of", "", "calculate result as of date yyyy-mm-dd, iss history is used for past dates (by default: today)")
+var issCacheArg = flag.String("iss-cache", "", "path to directory for iss responses snapshots, makes runs as of the same date reproducible (by default: disabled)")
+
 var threadPoolSizeArg = flag.Int("thread-pool-size", 10, "max number of goroutines for checking coupons and amortization")
 
 var emitentCacheArg = flag.String("emitent-cache", "emitent.cache", "path to output file")
 
 var outputFileArg = flag.String("output", "output.txt", "path to output file")
+var rejectedFileArg = flag.String("rejected-output", "", "path to rejected securities report (by default: not stored)")
+var jsonOutputArg = flag.String("json-output", "", "path to suitable bonds json array, used by portfolio swap mode (by default: not stored)")
+
+var explainArg = flag.String("explain", "", "explain why security with specified isin (or secid) is present/missing in result")
 
 var emitentBlacklist = flag.String("emitent-blacklist", "emitent.blacklist", "path to file, contains blacklisted companies (to exclude them from result)")
 var emitentComments = flag.String("emitent-comments", "emitent.comments", "path to file, contains comments for companies")
 var securitiesBlacklist = flag.String("securities-blacklist", "securities.blacklist", "path to file, contains blacklisted security names (to exclude them from result)")
 
-type Emitent struct {
-	Type  string `json:"type"`
-	Title string `json:"title"`
-	INN   string `json:"inn"`
-}
-
-func downloadEmitents() (map[string]*Emitent, error) {
-	var result = make(map[string]*Emitent)
-
-	var offset = 0
-	for {
-		var columns = []string{"secid", "type", "emitent_title", "emitent_inn"}
-
-		list := strings.Join(columns, ",")
-		url := fmt.Sprintf("https://iss.moex.com/iss/securities.json?engine=stock&market=bonds&iss.meta=off&securities.columns=%v&start=%v", list, offset)
-		log.Printf("requesting emitents `%v' ...", url)
-
-		resp, err := http.Get(url)
-		if err != nil {
-			return nil, fmt.Errorf("GET failed: %v", err)
-		}
-
-		data, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, fmt.Errorf("body read failed: %v", err)
-		}
-
-		var response struct {
-			Securities struct {
-				Data [][]string `json:"data"`
-			} `json:"securities"`
-		}
-		if err = json.Unmarshal(data, &response); err != nil {
-			return nil, fmt.Errorf("decode `%v' failed: %v", string(data), err)
-		}
-
-		for _, v := range response.Securities.Data {
-			if len(v) != len(columns) {
-				log.Fatalf("unknown format `%v'", string(data))
-			}
-
-			result[v[0]] = &Emitent{Type: v[1], Title: v[2], INN: v[3]}
-		}
-		if len(response.Securities.Data) == 0 {
-			break
-		}
-
-		offset += len(response.Securities.Data)
-	}
-
-	return result, nil
-}
-
 type Security struct {
 	ID        string `json:"secid"`
 	ISIN      string `json:"isin"`
@@ -133,43 +99,112 @@ type Security struct {
 		BondCount float64 `json:"bond_count"`
 	} `json:"lot"`
 
+	SettlementDate time.Time `json:"settlement_date"`
 	MaturityDate   time.Time `json:"maturity_date"`
 	DaysToMaturity float64   `json:"days_to_maturity"`
 	OfferDate      string    `json:"offer_date"`
 
 	YieldToMaturity    float64 `json:"yield_to_maturity"`
 	CurrentCouponYield float64 `json:"current_coupon_yield"`
+	Duration           float64 `json:"duration"` // macaulay (till the coupon reset for floaters), years
 
 	Amortization bool `json:"amortization"`
 
-	Emitent      *Emitent `json:"emitent"`
-	Comment      string   `json:"comment"`
-	ListingLevel float64  `json:"listing_level"`
+	Emitent      *moex.Emitent `json:"emitent"`
+	Comment      string        `json:"comment"`
+	Rating       string        `json:"rating,omitempty"` // the first credit rating mentioned in comment
+	ListingLevel float64       `json:"listing_level"`
 
 	MarketBoard  string `json:"market_board"`
 	RusbondsLink string `json:"rusbonds_link"`
+
+	Defaults  []*DefaultEvent `json:"defaults,omitempty"`
+	Position  *Position       `json:"position,omitempty"`
+	Rejection *Rejection      `json:"rejection,omitempty"`
+
+	incomeToMaturity float64 // per bond, after taxes
+
+	// bondization schedule, values are per bond
+	coupons       []moex.Payment
+	amortizations []moex.Payment
+	offers        []time.Time
+}
+
+// skip reasons, the order is used for skip stat output
+const (
+	rejectBlacklisted    = "blacklisted"
+	rejectDefault        = "emitent default"
+	rejectLowPrice       = "low price"
+	rejectLowCoupon      = "low coupon"
+	rejectLowCouponYield = "low current coupon yield"
+	rejectLowYield       = "low yield"
+	rejectMaturityDate   = "close/far maturity date"
+	rejectCouponType     = "non fixed coupon"
+	rejectAmortization   = "amortization"
+	rejectEmitentLimit   = "emitent issues limit"
+	rejectPositionLimit  = "lot exceeds position limit"
+)
+
+var rejectReasons = []string{
+	rejectBlacklisted,
+	rejectDefault,
+	rejectLowPrice,
+	rejectLowCoupon,
+	rejectLowCouponYield,
+	rejectLowYield,
+	rejectMaturityDate,
+	rejectCouponType,
+	rejectAmortization,
+	rejectPositionLimit,
+	rejectEmitentLimit,
+}
+
+// Rejection describes why security was excluded from the result
+type Rejection struct {
+	Reason    string `json:"reason"`
+	Value     string `json:"value"`
+	Threshold string `json:"threshold"`
+}
+
+func (r *Rejection) String() string {
+	return fmt.Sprintf("%v: `%v' (threshold: `%v')", r.Reason, r.Value, r.Threshold)
+}
+
+func (s *Security) reject(reason, value, threshold string) {
+	s.Rejection = &Rejection{Reason: reason, Value: value, Threshold: threshold}
 }
 
 func (s *Security) String() string {
-	data, err := json.MarshalIndent(s, "", "\t")
-	if err != nil {
-		log.Fatalf("can't encode `%+v' to json: %v", *s, err)
-	}
+	return toJSON(s)
+}
 
-	data = bytes.ReplaceAll(data, []byte("\\"), []byte{})
-	data = bytes.ReplaceAll(data, []byte("u0026"), []byte("&"))
+// toJSON encodes `v' into indented json for humans (links are kept readable)
+func toJSON(v interface{}) string {
+	var b bytes.Buffer
+	var enc = json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("can't encode `%+v' to json: %v", v, err)
+	}
 
-	return string(data)
+	return strings.TrimSuffix(b.String(), "\n")
 }
 
-func (s *Security) init() {
+func (s *Security) init(settlement time.Time, dayCount bond.DayCount) {
 	s.Nominal = s.Lot.Price / s.Lot.BondCount
-	s.DaysToMaturity = math.Round(s.MaturityDate.Sub(time.Now()).Hours() / 24)
+	s.SettlementDate = settlement
+	s.DaysToMaturity = bond.Days(settlement, s.MaturityDate)
+
+	if coupon, ok := s.currentCoupon(settlement); ok {
+		// iss value is calculated for the current day, not for the settlement one
+		s.Coupon.AccruedInterest = math.Round(dayCount.AccruedInterest(coupon, settlement)*100) / 100
+	}
 
 	s.CleanPrice = s.Nominal * s.CleanPricePercent / 100.0
 	s.DirtyPrice = (s.CleanPrice + s.Coupon.AccruedInterest)
 
-	var futureCoupon = s.Nominal * (s.Coupon.Percent / 100.0) * (s.DaysToMaturity / 365.0)
+	var futureCoupon = s.Nominal * (s.Coupon.Percent / 100.0) * dayCount.YearFraction(settlement, s.MaturityDate)
 	var accurredInterest = s.Coupon.AccruedInterest // `futureCoupon' doesn't include it
 
 	var taxPercent = *taxPercentArg
@@ -181,12 +216,57 @@ func (s *Security) init() {
 	var income = s.Nominal + accurredInterest + futureCoupon - taxes
 	var spent = s.DirtyPrice
 	s.YieldToMaturity = (income/spent - 1) * (365.0 / s.DaysToMaturity) * 100.0
+	s.incomeToMaturity = income
 
 	s.CurrentCouponYield = (s.Nominal * s.Coupon.Percent / 100.0) * (1 - taxPercent) / s.CleanPrice * 100.0
 
 	s.RusbondsLink = fmt.Sprintf("https://www.old.rusbonds.ru/srch_simple.asp?go=1&nick=%v", s.ISIN)
 }
 
+// fillCouponPeriod sets the next coupon date and the coupon period using bondization when they
+// are unknown (iss history doesn't contain them), false is returned if nothing is changed
+func (s *Security) fillCouponPeriod(today time.Time) bool {
+	if s.Coupon.NextCouponDate != "" {
+		return false
+	}
+
+	next, ok := s.nextCoupon(today)
+	if !ok {
+		return false
+	}
+
+	for i := 1; i < len(s.coupons); i++ {
+		if s.coupons[i].Date.Equal(next) {
+			s.Coupon.NextCouponDate = next.Format(moex.DateFormat)
+			s.Coupon.Period = bond.Days(s.coupons[i-1].Date, next)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// currentCoupon returns coupon period containing `date'
+func (s *Security) currentCoupon(date time.Time) (bond.Coupon, bool) {
+	var coupon = bond.Coupon{Nominal: s.Nominal, Percent: s.Coupon.Percent, Value: s.Coupon.Value}
+
+	next, err := moex.ParseDate(s.Coupon.NextCouponDate)
+	if err != nil || s.Coupon.Period <= 0 {
+		return coupon, false
+	}
+
+	var period = int(s.Coupon.Period)
+	for !date.Before(next) {
+		next = next.AddDate(0, 0, period)
+	}
+
+	coupon.Start = next.AddDate(0, 0, -period)
+	coupon.End = next
+
+	return coupon, true
+}
+
 func downloadSecurities() (map[string]*Security, error) {
 	var columns = []string{
 		"SECID",
@@ -210,32 +290,20 @@ func downloadSecurities() (map[string]*Security, error) {
 
 	list := strings.Join(columns, ",")
 	url := fmt.Sprintf("https://iss.moex.com/iss/engines/stock/markets/bonds/securities.json?iss.meta=off&iss.only=securities&securities.columns=%v", list)
-	log.Printf("downloading securities `%v' ...", url)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("GET failed: %v", err)
-	}
-
-	data, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		return nil, fmt.Errorf("body read failed: %v", err)
-	}
 
 	var response struct {
 		Securities struct {
 			Data [][]interface{} `json:"data"`
 		} `json:"securities"`
 	}
-	if err = json.Unmarshal(data, &response); err != nil {
-		return nil, fmt.Errorf("decode `%v' failed: %v", string(data), err)
+	if err := moex.Get(url, &response); err != nil {
+		return nil, err
 	}
 
 	var result = make(map[string]*Security)
 	for _, v := range response.Securities.Data {
 		if len(v) != len(columns) {
-			log.Fatalf("unknown format `%v'", string(data))
+			log.Fatalf("unknown format `%v'", v)
 		}
 
 		var secid = v[0].(string)
@@ -264,15 +332,11 @@ func downloadSecurities() (map[string]*Security, error) {
 			sec.OfferDate = v[11].(string)
 		}
 
-		var date = v[12].(string)
-		if date == "0000-00-00" {
-			// it will be excluded by maturity date
-			date = "3999-01-01"
-		}
-		sec.MaturityDate, err = time.Parse("2006-01-02", date)
+		maturityDate, err := parseMaturityDate(v[12].(string))
 		if err != nil {
-			return nil, fmt.Errorf("can't decode maturity date `%v': %v", date, err)
+			return nil, err
 		}
+		sec.MaturityDate = maturityDate
 
 		sec.Currency = v[13].(string)
 		if v[14] != nil {
@@ -289,69 +353,158 @@ func downloadSecurities() (map[string]*Security, error) {
 	return result, nil
 }
 
-func (s *Security) downloadBondization() error {
-	var amortizationColumns = []string{"amortdate", "valueprc", "value"}
-	var couponsColumns = []string{"coupondate", "valueprc", "value"}
-	var offerColumns = []string{"offerdate", "offerdatestart", "offerdateend", "offertype"}
+// downloadSecuritiesHistory returns securities traded at `date', iss history doesn't contain
+// coupon schedule, lot size and listing level, so accrued interest isn't recalculated
+// for the settlement date and lot is considered to be a single bond
+func downloadSecuritiesHistory(date time.Time) (map[string]*Security, error) {
+	var columns = []string{
+		"SECID",
+		"SHORTNAME",
+		"BOARDID",
+		"LEGALCLOSEPRICE",
+		"ACCINT",
+		"COUPONPERCENT",
+		"COUPONVALUE",
+		"MATDATE",
+		"FACEVALUE",
+		"FACEUNIT",
+		"OFFERDATE",
+	}
 
-	url := fmt.Sprintf("http://iss.moex.com/iss/securities/%v/bondization.json?limit=unlimited&iss.meta=off&amortizations.columns=%v&coupons.columns=%v&offers.columns=%v",
-		s.ID, strings.Join(amortizationColumns, ","), strings.Join(couponsColumns, ","), strings.Join(offerColumns, ","))
-	log.Printf("downloading bondization `%v' ...", url)
+	url := fmt.Sprintf("https://iss.moex.com/iss/history/engines/stock/markets/bonds/securities.json?iss.meta=off&date=%v&history.columns=%v",
+		date.Format(moex.DateFormat), strings.Join(columns, ","))
 
-	resp, err := http.Get(url)
+	rows, err := moex.GetHistory(url)
 	if err != nil {
-		return fmt.Errorf("GET failed: %v", err)
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("there are no trades at `%v' (is it a trading day?)", date.Format(moex.DateFormat))
 	}
 
-	data, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		return fmt.Errorf("body read failed: %v", err)
+	var result = make(map[string]*Security)
+	for _, v := range rows {
+		if len(v) != len(columns) {
+			log.Fatalf("unknown format `%v'", v)
+		}
+
+		var secid = moex.String(v[0])
+		var sec = result[secid]
+		if sec == nil {
+			// bonds secid is isin
+			sec = &Security{ID: secid, ISIN: secid}
+			result[secid] = sec
+		}
+
+		sec.ShortName = moex.String(v[1])
+		sec.SecName = sec.ShortName
+
+		sec.Coupon.AccruedInterest = moex.Float(v[4])
+		sec.Coupon.Percent = moex.Float(v[5])
+		sec.Coupon.Value = moex.Float(v[6])
+
+		maturityDate, err := parseMaturityDate(moex.String(v[7]))
+		if err != nil {
+			return nil, err
+		}
+		sec.MaturityDate = maturityDate
+
+		sec.Lot.Price = moex.Float(v[8])
+		sec.Lot.BondCount = 1
+
+		sec.Currency = moex.String(v[9])
+		sec.OfferDate = moex.String(v[10])
+
+		if v[3] != nil {
+			// override price and market only when price is available to make it consistent
+			sec.CleanPricePercent = moex.Float(v[3])
+			sec.MarketBoard = moex.String(v[2])
+		}
 	}
 
-	var response struct {
-		Amortizations struct {
-			Data [][]interface{} `json:"data"`
-		} `json:"amortizations"`
-		Coupons struct {
-			Data [][]interface{} `json:"data"`
-		} `json:"coupons"`
-		Offers struct {
-			Data [][]interface{} `json:"data"`
-		} `json:"offers"`
+	return result, nil
+}
+
+func parseMaturityDate(date string) (time.Time, error) {
+	if date == "0000-00-00" || date == "" {
+		// it will be excluded by maturity date
+		date = "3999-01-01"
 	}
-	if err = json.Unmarshal(data, &response); err != nil {
-		return fmt.Errorf("decode `%v' failed: %v", string(data), err)
+
+	t, err := moex.ParseDate(date)
+	if err != nil {
+		return t, fmt.Errorf("can't decode maturity date `%v': %v", date, err)
 	}
 
-	if len(response.Amortizations.Data) > 1 {
-		s.Amortization = true
+	return t, nil
+}
+
+func (s *Security) downloadBondization() error {
+	b, err := moex.DownloadBondization(s.ID)
+	if err != nil {
+		return err
 	}
 
-	s.Coupon.IsFixed = true
-	s.Coupon.IsConstant = true
-	for i := 0; i < len(response.Coupons.Data); i++ {
-		if response.Coupons.Data[i][1] == nil {
-			s.Coupon.IsConstant = false
-			s.Coupon.IsFixed = false
-			break
-		}
+	s.Amortization = len(b.Amortizations) > 1
+	s.coupons = b.Coupons
+	s.amortizations = b.Amortizations
+	s.offers = b.Offers
 
-		if i > 0 && response.Coupons.Data[i-1][1] != response.Coupons.Data[i][1] {
-			s.Coupon.IsConstant = false
+	s.Coupon.IsFixed = b.FixedCoupon
+	s.Coupon.IsConstant = b.ConstantCoupon
+
+	return nil
+}
+
+// calcDuration calculates duration using bondization schedule, unknown coupons
+// are expected to be equal to the current one; floating coupons bonds are
+// sensitive to rates till the first unknown coupon (like held ones in portfolio)
+func (s *Security) calcDuration() {
+	var payments bond.Schedule
+	for _, c := range s.coupons {
+		var value = c.Value
+		if value == 0 {
+			value = s.Coupon.Value
+			if payments.Reset.IsZero() && c.Date.After(s.SettlementDate) {
+				payments.Reset = c.Date
+			}
 		}
+
+		payments.Coupons = append(payments.Coupons, bond.CashFlow{Date: c.Date, Value: value})
+	}
+	for _, a := range s.amortizations {
+		payments.Amortizations = append(payments.Amortizations, bond.CashFlow{Date: a.Date, Value: a.Value})
+	}
+	if len(s.amortizations) == 0 {
+		payments.Amortizations = append(payments.Amortizations, bond.CashFlow{Date: s.MaturityDate, Value: s.Nominal})
 	}
 
-	return nil
+	_, risk, err := payments.Risk(s.SettlementDate, s.DirtyPrice)
+	if err != nil {
+		return
+	}
+
+	s.Duration = risk.Macaulay
+}
+
+var ratingRegexp = regexp.MustCompile(`рейтинг\s+((?:ru)?[ABCDАВС]{1,3}[+-]?(?:\(RU\)|\|ru\||\.ru)?)(?:[\s,;)]|$)`)
+
+// ratingFromComment returns the first credit rating mentioned in emitent comment
+func ratingFromComment(comment string) string {
+	m := ratingRegexp.FindStringSubmatch(comment)
+	if m == nil {
+		return ""
+	}
+
+	return m[1]
 }
 
 func main() {
-	var wg sync.WaitGroup
 	var err error
 
 	flag.Parse()
 
-	var excludeEmitent = make([]string, 0)
+	var sc = Screen{EmitentComment: make(map[string]string)}
 	if *emitentBlacklist != "" {
 		f, err := os.Open(*emitentBlacklist)
 		if err != nil {
@@ -366,14 +519,13 @@ func main() {
 				continue
 			}
 
-			excludeEmitent = append(excludeEmitent, line)
+			sc.ExcludeEmitent = append(sc.ExcludeEmitent, line)
 		}
 		if err = scanner.Err(); err != nil {
 			log.Fatalf("emitent blacklist scan failed: %v", err)
 		}
 	}
 
-	var emitentComment = make(map[string]string)
 	if *emitentComments != "" {
 		f, err := os.Open(*emitentComments)
 		if err != nil {
@@ -393,14 +545,13 @@ func main() {
 				log.Fatalf("bad comment format `%v' (expected: `emitent' -> `comment'", line)
 			}
 
-			emitentComment[parts[0]] = parts[1]
+			sc.EmitentComment[parts[0]] = parts[1]
 		}
 		if err = scanner.Err(); err != nil {
 			log.Fatalf("emitent comments scan failed: %v", err)
 		}
 	}
 
-	var excludeSecurities = make([]string, 0)
 	if *securitiesBlacklist != "" {
 		f, err := os.Open(*securitiesBlacklist)
 		if err != nil {
@@ -415,59 +566,82 @@ func main() {
 				continue
 			}
 
-			excludeSecurities = append(excludeSecurities, line)
+			sc.ExcludeSecurities = append(sc.ExcludeSecurities, line)
 		}
 		if err = scanner.Err(); err != nil {
 			log.Fatalf("securities blacklist scan failed: %v", err)
 		}
 	}
 
-	var minMaturityDate = time.Now().AddDate(1, 0, 0) // skip 1 years from now
-	if *minMaturityDateArg != "" {
-		date, err := time.Parse("2006-01-02", *minMaturityDateArg)
+	if *defaultsActionArg != "exclude" && *defaultsActionArg != "warn" {
+		log.Fatalf("bad `-defaults-action' `%v' (expected: exclude or warn)", *defaultsActionArg)
+	}
+	if *defaultsRegistryArg != "" {
+		sc.Defaults, err = loadDefaultsRegistry(*defaultsRegistryArg)
 		if err != nil {
-			log.Fatal("can't parse maturity date: ", err)
+			log.Fatalf("can't load defaults registry: %v", err)
 		}
+	}
 
-		minMaturityDate = date
+	sc.DayCount, err = bond.ParseDayCount(*dayCountArg)
+	if err != nil {
+		log.Fatalf("bad `-day-count': %v", err)
 	}
 
-	var maxMaturityDate = time.Now().AddDate(3, 0, 0) // skip 3 years from now
-	if *maxMaturityDateArg != "" {
-		date, err := time.Parse("2006-01-02", *maxMaturityDateArg)
+	sc.Calendar = moex.NewCalendar()
+	if *calendarArg != "" {
+		sc.Calendar, err = moex.LoadCalendar(*calendarArg)
 		if err != nil {
-			log.Fatal("can't parse max maturity date: ", err)
+			log.Fatalf("can't load calendar: %v", err)
 		}
-
-		maxMaturityDate = date
 	}
 
-	wg.Add(2)
+	moex.CacheDir = *issCacheArg
 
-	var secid2emitent map[string]*Emitent
-	go func() {
-		defer wg.Done()
+	var today = moex.Today()
+	if *asOfArg != "" {
+		date, err := moex.ParseDate(*asOfArg)
+		if err != nil {
+			log.Fatalf("can't parse `-as-of' date: %v", err)
+		}
+		if date.After(today) {
+			log.Fatalf("`-as-of' date `%v' is in the future", *asOfArg)
+		}
 
-		if *emitentCacheArg != "" {
-			data, err := ioutil.ReadFile(*emitentCacheArg)
-			if err == nil {
-				if err = json.Unmarshal(data, &secid2emitent); err == nil {
-					return
-				}
+		today = date
+	}
+	moex.CacheDate = today
+
+	if *sharesArg {
+		if today.Before(moex.Today()) {
+			log.Fatal("`-as-of' isn't supported in shares mode")
+		}
+		if err = runShares(&sc, today); err != nil {
+			log.Fatalf("can't screen shares: %v", err)
+		}
+
+		return
+	}
 
+	if *emitentCacheArg != "" {
+		data, err := ioutil.ReadFile(*emitentCacheArg)
+		if err == nil {
+			if err = json.Unmarshal(data, &sc.Emitents); err != nil {
 				log.Printf("can't decode emitents cache `%v' (will be requested again): %v", *emitentCacheArg, err)
-			} else if !os.IsNotExist(err) {
-				log.Fatalf("can't read emitents cache `%v': %v", *emitentCacheArg, err)
 			}
+		} else if !os.IsNotExist(err) {
+			log.Fatalf("can't read emitents cache `%v': %v", *emitentCacheArg, err)
 		}
+	}
 
-		secid2emitent, err = downloadEmitents()
+	if sc.Emitents == nil {
+		sc.Emitents, err = moex.DownloadEmitents("bonds")
 		if err != nil {
 			log.Fatalf("can't download emitents: %v", err)
 		}
 
 		if *emitentCacheArg != "" {
-			data, err := json.Marshal(&secid2emitent)
+			data, err := json.Marshal(&sc.Emitents)
 			if err != nil {
 				log.Fatalf("can't encode emitents cache: %v", err)
 			}
@@ -475,172 +649,142 @@ func main() {
 				log.Fatalf("can't store emitents cache into `%v': %v", *emitentCacheArg, err)
 			}
 		}
-	}()
-
-	var securities map[string]*Security
-	go func() {
-		defer wg.Done()
+	}
 
-		securities, err = downloadSecurities()
-		if err != nil {
-			log.Fatalf("can't download securities: %v", err)
+	if *backtestFromArg != "" {
+		if err = backtest(&sc, today); err != nil {
+			log.Fatalf("backtest failed: %v", err)
 		}
-	}()
 
-	wg.Wait()
+		return
+	}
 
-	var blacklisted, skipLowPrice, skipLowCouponPercent, skipLowCouponYield, skipLowYield, skipMaturityDate, skipCouponType, skipAmortization int
-	for secid, v := range securities {
-		var skip bool
+	result, err := sc.run(today, nil)
+	if err != nil {
+		log.Fatalf("can't screen securities: %v", err)
+	}
 
-		v.init()
+	var skipStat = make(map[string]int)
+	for _, v := range result.Rejected {
+		skipStat[v.Rejection.Reason]++
+	}
 
-		if e := secid2emitent[secid]; e != nil {
-			v.Emitent = e
+	log.Printf("\nskip stat:\n")
+	for _, reason := range rejectReasons {
+		log.Printf("\t%v: %v\n", reason, skipStat[reason])
+	}
+	log.Println()
 
-			for _, exclude := range excludeEmitent {
-				if strings.Contains(v.Emitent.Title, exclude) {
-					skip = true
-					break
-				}
-			}
+	log.Println("Storing results ...")
+	file, err := os.OpenFile(*outputFileArg, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
 
-			v.Comment = emitentComment[e.Title]
-		} else {
-			log.Printf("emitent for `%v' not found", secid)
-		}
+	if *groupByEmitentArg {
+		for _, g := range result.Groups {
+			if _, err = fmt.Fprintf(file, "%v\n\n", g); err != nil {
+				log.Fatalf("can't store results into `%v': %v", *outputFileArg, err)
+			}
 
-		if skip == false {
-			for _, exclude := range excludeSecurities {
-				if strings.Contains(v.ISIN, exclude) || strings.Contains(v.ShortName, exclude) || strings.Contains(v.SecName, exclude) {
-					skip = true
-					break
+			for i, b := range g.Bonds {
+				_, err = fmt.Fprintf(file, "%v: %v\n\n", i, b)
+				if err != nil {
+					log.Fatalf("can't store results into `%v': %v", *outputFileArg, err)
 				}
 			}
 		}
-		if skip == true {
-			delete(securities, secid)
-			blacklisted++
-
-			continue
+	} else {
+		for i, b := range result.Bonds {
+			_, err = fmt.Fprintf(file, "%v: %v\n\n", i, b)
+			if err != nil {
+				log.Fatalf("can't store results into `%v': %v", *outputFileArg, err)
+			}
 		}
+	}
 
-		if v.CleanPricePercent < *minCleanPricePercentArg {
-			delete(securities, secid)
-			skipLowPrice++
+	log.Printf("Results stored into `%s'", *outputFileArg)
 
-			continue
+	if *rejectedFileArg != "" {
+		if err = storeRejected(*rejectedFileArg, result.Rejected); err != nil {
+			log.Fatalf("can't store rejected securities: %v", err)
 		}
-		if v.Coupon.Percent < *minCouponPercentArg {
-			delete(securities, secid)
-			skipLowCouponPercent++
 
-			continue
-		}
-		if v.CurrentCouponYield < *minCouponYieldArg {
-			delete(securities, secid)
-			skipLowCouponYield++
+		log.Printf("Rejected securities stored into `%s'", *rejectedFileArg)
+	}
 
-			continue
+	if *jsonOutputArg != "" {
+		if err = storeJSON(*jsonOutputArg, result.Bonds); err != nil {
+			log.Fatalf("can't store json output: %v", err)
 		}
 
-		if minMaturityDate.After(v.MaturityDate) || maxMaturityDate.Before(v.MaturityDate) {
-			skipMaturityDate++
-			delete(securities, secid)
-
-			continue
-		}
+		log.Printf("Suitable bonds stored into `%s'", *jsonOutputArg)
 	}
 
-	var ch = make(chan *Security, *threadPoolSizeArg)
-	wg.Add(*threadPoolSizeArg)
-	for i := 0; i < *threadPoolSizeArg; i++ {
-		go func() {
-			defer wg.Done()
-
-			for {
-				var sec = <-ch
-				if sec == nil {
-					return
-				}
-
-				if err := sec.downloadBondization(); err != nil {
-					log.Printf("can't donwnload coupon/amortization/offers info for `%v': %v", sec, err)
-				}
-			}
-		}()
-	}
-	for _, v := range securities {
-		ch <- v
+	if *explainArg != "" {
+		explain(*explainArg, result.Bonds, result.Rejected)
 	}
-	close(ch)
+}
 
-	wg.Wait()
+func matchSecurity(s *Security, id string) bool {
+	return s.ISIN == id || s.ID == id
+}
 
-	var bonds []*Security
-	for _, v := range securities {
-		if (v.Coupon.IsFixed == false || v.Coupon.IsConstant == false) && *anyCouponTypesArg == false {
-			skipCouponType++
-			continue
+// explain prints the reason why security is present or missing in result
+func explain(id string, bonds, rejected []*Security) {
+	for i, b := range bonds {
+		if matchSecurity(b, id) {
+			log.Printf("explain `%v': accepted, position %v (yield to maturity: %.2f%%, current coupon yield: %.2f%%)",
+				id, i, b.YieldToMaturity, b.CurrentCouponYield)
+			return
 		}
+	}
 
-		if v.Amortization && *anyRedemptionTypesArg == false {
-			skipAmortization++
-			continue
+	for _, r := range rejected {
+		if matchSecurity(r, id) {
+			log.Printf("explain `%v' (%v): rejected, %v", id, r.ShortName, r.Rejection)
+			return
 		}
+	}
 
-		// skip only contants coupons because of low yield, because yield for other bond types could be incorrect
-		var minYieldPercent float64
-		switch v.Currency {
-		case "SUR":
-			minYieldPercent = *minRubSuitablePercentArg
-		case "USD":
-			minYieldPercent = *minUsdSuitablePercentArg
-		case "EUR":
-			minYieldPercent = *minEurSuitablePercentArg
-		}
-		if v.Coupon.IsFixed && v.YieldToMaturity < minYieldPercent {
-			skipLowYield++
-			continue
+	log.Printf("explain `%v': not found in moex securities list", id)
+}
+
+func storeRejected(path string, rejected []*Security) error {
+	sort.Slice(rejected, func(i, j int) bool {
+		if rejected[i].Rejection.Reason != rejected[j].Rejection.Reason {
+			return rejected[i].Rejection.Reason < rejected[j].Rejection.Reason
 		}
 
-		bonds = append(bonds, v)
+		return rejected[i].ISIN < rejected[j].ISIN
+	})
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
 
-	log.Printf("\nskip stat:\n")
-	log.Printf("\tblacklisted: %v\n", blacklisted)
-	log.Printf("\tlow price: %v\n", skipLowPrice)
-	log.Printf("\tlow coupon: %v\n", skipLowCouponPercent)
-	log.Printf("\tlow current coupon yield: %v\n", skipLowCouponYield)
-	log.Printf("\tlow yield: %v\n", skipLowYield)
-	log.Printf("\tclose/far maturity date: %v\n", skipMaturityDate)
-	log.Printf("\tnon fixed coupon: %v\n", skipCouponType)
-	log.Printf("\tamortization: %v\n\n", skipAmortization)
-
-	log.Printf("Sorting `%v' results ...", len(bonds))
-	if *sortByCurrentCouponYieldArg {
-		sort.Slice(bonds, func(i, j int) bool {
-			return bonds[i].CurrentCouponYield > bonds[j].CurrentCouponYield
-		})
-	} else {
-		sort.Slice(bonds, func(i, j int) bool {
-			return bonds[i].YieldToMaturity > bonds[j].YieldToMaturity
-		})
+	for _, r := range rejected {
+		if _, err = fmt.Fprintf(file, "%v\t%v\t%v\t%v\n", r.ISIN, r.ID, r.ShortName, r.Rejection); err != nil {
+			return fmt.Errorf("can't write into `%v': %v", path, err)
+		}
 	}
 
-	log.Println("Storing results ...")
-	file, err := os.OpenFile(*outputFileArg, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	return nil
+}
+
+// storeJSON stores bonds as json array
+func storeJSON(path string, bonds []*Security) error {
+	data, err := json.MarshalIndent(bonds, "", "\t")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer file.Close()
 
-	for i, b := range bonds {
-		_, err = fmt.Fprintf(file, "%v: %v\n\n", i, b)
-		if err != nil {
-			log.Fatal("can't store results into `%v'", *outputFileArg, err)
-		}
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("can't write into `%v': %v", path, err)
 	}
 
-	log.Printf("Results stored into `%s'", *outputFileArg)
+	return nil
 }
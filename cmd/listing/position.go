@@ -0,0 +1,36 @@
+package main
+
+import "math"
+
+// Position describes how much bonds could be bought using the budget,
+// all values are in rubles
+type Position struct {
+	Lots       float64 `json:"lots"`
+	BondCount  float64 `json:"bond_count"`
+	LotCost    float64 `json:"lot_cost"` // including accrued interest and commission
+	Cost       float64 `json:"cost"`
+	Commission float64 `json:"commission"`
+
+	YearCouponCashFlow float64 `json:"year_coupon_cash_flow"` // after taxes
+	CashFlowToMaturity float64 `json:"cash_flow_to_maturity"` // coupons and nominal after taxes
+
+	CurrencyRate float64 `json:"currency_rate"`
+}
+
+func newPosition(s *Security, rate, limit float64) *Position {
+	var p = Position{CurrencyRate: rate}
+
+	var lotPrice = s.DirtyPrice * s.Lot.BondCount * rate
+	var lotCommission = lotPrice * *commissionPercentArg / 100.0
+
+	p.LotCost = lotPrice + lotCommission
+	p.Lots = math.Floor(limit / p.LotCost)
+	p.BondCount = p.Lots * s.Lot.BondCount
+	p.Cost = p.Lots * p.LotCost
+	p.Commission = p.Lots * lotCommission
+
+	p.YearCouponCashFlow = p.BondCount * s.Nominal * s.Coupon.Percent / 100.0 * (1 - *taxPercentArg) * rate
+	p.CashFlowToMaturity = p.BondCount * s.incomeToMaturity * rate
+
+	return &p
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spectrec/invest-tools/bond"
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// Screen contains everything needed to filter securities, except the date,
+// so it could be run for several dates (see backtest)
+type Screen struct {
+	ExcludeEmitent    []string
+	ExcludeSecurities []string
+	EmitentComment    map[string]string
+
+	Emitents map[string]*moex.Emitent   // secid -> emitent
+	Defaults map[string][]*DefaultEvent // inn -> known defaults
+
+	DayCount bond.DayCount
+	Calendar *moex.Calendar
+}
+
+// ScreenResult contains sorted suitable bonds and rejected ones
+type ScreenResult struct {
+	Bonds    []*Security
+	Groups   []*EmitentGroup
+	Rejected []*Security
+
+	SettlementDate time.Time
+}
+
+// run filters securities as of `today' date, securities are downloaded when they
+// are not specified (iss history is used for past dates)
+func (sc *Screen) run(today time.Time, securities map[string]*Security) (*ScreenResult, error) {
+	var result = ScreenResult{SettlementDate: sc.Calendar.SettlementDate(today, *settlementDaysArg)}
+	log.Printf("settlement date: %v", result.SettlementDate.Format(moex.DateFormat))
+
+	var minMaturityDate = today.AddDate(1, 0, 0) // skip 1 years from now
+	if *minMaturityDateArg != "" {
+		date, err := moex.ParseDate(*minMaturityDateArg)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse maturity date: %v", err)
+		}
+
+		minMaturityDate = date
+	}
+
+	var maxMaturityDate = today.AddDate(3, 0, 0) // skip 3 years from now
+	if *maxMaturityDateArg != "" {
+		date, err := moex.ParseDate(*maxMaturityDateArg)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse max maturity date: %v", err)
+		}
+
+		maxMaturityDate = date
+	}
+
+	if securities == nil {
+		var err error
+		if today.Before(moex.Today()) {
+			securities, err = downloadSecuritiesHistory(today)
+		} else {
+			securities, err = downloadSecurities()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't download securities: %v", err)
+		}
+	}
+
+	var defaults = make(map[string][]*DefaultEvent)
+	for inn, events := range sc.Defaults {
+		defaults[inn] = append(defaults[inn], events...)
+	}
+
+	// defaulted bonds usually trade below the min price, their schedules
+	// are checked too to flag the rest bonds of the emitent
+	var lowPrice []*Security
+
+	for secid, v := range securities {
+		v.init(result.SettlementDate, sc.DayCount)
+
+		if e := sc.Emitents[secid]; e != nil {
+			v.Emitent = e
+
+			for _, exclude := range sc.ExcludeEmitent {
+				if strings.Contains(v.Emitent.Title, exclude) {
+					v.reject(rejectBlacklisted, v.Emitent.Title, "emitent blacklist: "+exclude)
+					break
+				}
+			}
+
+			v.Comment = sc.EmitentComment[e.Title]
+			v.Rating = ratingFromComment(v.Comment)
+		} else {
+			log.Printf("emitent for `%v' not found", secid)
+		}
+
+		if v.Rejection == nil {
+			for _, exclude := range sc.ExcludeSecurities {
+				if strings.Contains(v.ISIN, exclude) || strings.Contains(v.ShortName, exclude) || strings.Contains(v.SecName, exclude) {
+					v.reject(rejectBlacklisted, v.ShortName, "securities blacklist: "+exclude)
+					break
+				}
+			}
+		}
+
+		v.checkDefaults(defaults, today)
+
+		if v.Rejection == nil && v.CleanPricePercent < *minCleanPricePercentArg {
+			v.reject(rejectLowPrice, fmt.Sprintf("%.2f%%", v.CleanPricePercent), fmt.Sprintf("%.2f%%", *minCleanPricePercentArg))
+			if v.Emitent != nil && v.Emitent.INN != "" {
+				lowPrice = append(lowPrice, v)
+			}
+		}
+		if v.Rejection == nil && v.Coupon.Percent < *minCouponPercentArg {
+			v.reject(rejectLowCoupon, fmt.Sprintf("%.2f%%", v.Coupon.Percent), fmt.Sprintf("%.2f%%", *minCouponPercentArg))
+		}
+		if v.Rejection == nil && v.CurrentCouponYield < *minCouponYieldArg {
+			v.reject(rejectLowCouponYield, fmt.Sprintf("%.2f%%", v.CurrentCouponYield), fmt.Sprintf("%.2f%%", *minCouponYieldArg))
+		}
+
+		if v.Rejection == nil && (minMaturityDate.After(v.MaturityDate) || maxMaturityDate.Before(v.MaturityDate)) {
+			v.reject(rejectMaturityDate, v.MaturityDate.Format(moex.DateFormat),
+				minMaturityDate.Format(moex.DateFormat)+" .. "+maxMaturityDate.Format(moex.DateFormat))
+		}
+
+		if v.Rejection != nil {
+			result.Rejected = append(result.Rejected, v)
+			delete(securities, secid)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var ch = make(chan *Security, *threadPoolSizeArg)
+	wg.Add(*threadPoolSizeArg)
+	for i := 0; i < *threadPoolSizeArg; i++ {
+		go func() {
+			defer wg.Done()
+
+			for {
+				var sec = <-ch
+				if sec == nil {
+					return
+				}
+
+				if err := sec.downloadBondization(); err != nil {
+					log.Printf("can't donwnload coupon/amortization/offers info for `%v': %v", sec, err)
+					continue
+				}
+
+				if sec.fillCouponPeriod(today) {
+					// accrued interest of history is recalculated for the settlement date
+					sec.init(result.SettlementDate, sc.DayCount)
+				}
+				sec.calcDuration()
+			}
+		}()
+	}
+	for _, v := range securities {
+		ch <- v
+	}
+	for _, v := range lowPrice {
+		ch <- v
+	}
+	close(ch)
+
+	wg.Wait()
+
+	// missed payments are detected using bondization schedules of the rest and
+	// low price bonds, emitent events affect all its bonds
+	var checked = lowPrice
+	for _, v := range securities {
+		checked = append(checked, v)
+	}
+	sort.Slice(checked, func(i, j int) bool {
+		return checked[i].ID < checked[j].ID
+	})
+	for _, v := range checked {
+		if v.Emitent == nil || v.Emitent.INN == "" {
+			continue
+		}
+
+		for _, event := range []*DefaultEvent{v.detectDefault(today, sc.Calendar), v.detectMissedAmortization(today, sc.Calendar)} {
+			if event != nil {
+				defaults[v.Emitent.INN] = append(defaults[v.Emitent.INN], event)
+			}
+		}
+	}
+
+	var currencyRates = map[string]float64{"SUR": 1.0}
+	if *budgetArg > 0 {
+		for _, v := range securities {
+			if _, ok := currencyRates[v.Currency]; ok {
+				continue
+			}
+
+			rate, err := moex.DownloadCurrencyRate(v.Currency)
+			if err != nil {
+				log.Printf("can't download `%v' rate (position won't be calculated): %v", v.Currency, err)
+			}
+
+			currencyRates[v.Currency] = rate
+		}
+	}
+
+	var bonds []*Security
+	for _, v := range securities {
+		if v.checkDefaults(defaults, today); v.Rejection != nil {
+			result.Rejected = append(result.Rejected, v)
+			continue
+		}
+
+		if (v.Coupon.IsFixed == false || v.Coupon.IsConstant == false) && *anyCouponTypesArg == false {
+			v.reject(rejectCouponType, fmt.Sprintf("fixed: %v, constant: %v", v.Coupon.IsFixed, v.Coupon.IsConstant), "fixed and constant")
+			result.Rejected = append(result.Rejected, v)
+
+			continue
+		}
+
+		if v.Amortization && *anyRedemptionTypesArg == false {
+			v.reject(rejectAmortization, "amortization", "no amortization")
+			result.Rejected = append(result.Rejected, v)
+
+			continue
+		}
+
+		// skip only contants coupons because of low yield, because yield for other bond types could be incorrect
+		var minYieldPercent float64
+		switch v.Currency {
+		case "SUR":
+			minYieldPercent = *minRubSuitablePercentArg
+		case "USD":
+			minYieldPercent = *minUsdSuitablePercentArg
+		case "EUR":
+			minYieldPercent = *minEurSuitablePercentArg
+		}
+		if v.Coupon.IsFixed && v.YieldToMaturity < minYieldPercent {
+			v.reject(rejectLowYield, fmt.Sprintf("%.2f%%", v.YieldToMaturity), fmt.Sprintf("%.2f%% (%v)", minYieldPercent, v.Currency))
+			result.Rejected = append(result.Rejected, v)
+
+			continue
+		}
+
+		if *budgetArg > 0 && currencyRates[v.Currency] > 0 {
+			v.Position = newPosition(v, currencyRates[v.Currency], *budgetArg*(*maxPositionPercentArg)/100.0)
+			if v.Position.Lots == 0 {
+				v.reject(rejectPositionLimit, fmt.Sprintf("%.2f RUB", v.Position.LotCost),
+					fmt.Sprintf("%.2f RUB (%.1f%% of %.2f)", *budgetArg*(*maxPositionPercentArg)/100.0, *maxPositionPercentArg, *budgetArg))
+				result.Rejected = append(result.Rejected, v)
+
+				continue
+			}
+		}
+
+		bonds = append(bonds, v)
+	}
+
+	log.Printf("Sorting `%v' results ...", len(bonds))
+	// isin is used to make the order stable (result must be reproducible)
+	if *sortByCurrentCouponYieldArg {
+		sort.Slice(bonds, func(i, j int) bool {
+			if bonds[i].CurrentCouponYield != bonds[j].CurrentCouponYield {
+				return bonds[i].CurrentCouponYield > bonds[j].CurrentCouponYield
+			}
+
+			return bonds[i].ISIN < bonds[j].ISIN
+		})
+	} else {
+		sort.Slice(bonds, func(i, j int) bool {
+			if bonds[i].YieldToMaturity != bonds[j].YieldToMaturity {
+				return bonds[i].YieldToMaturity > bonds[j].YieldToMaturity
+			}
+
+			return bonds[i].ISIN < bonds[j].ISIN
+		})
+	}
+
+	if *groupByEmitentArg {
+		result.Groups = groupByEmitent(bonds, *bestPerEmitentArg)
+	}
+
+	if *bestPerEmitentArg > 0 {
+		bonds, result.Rejected = keepBestPerEmitent(bonds, result.Rejected, *bestPerEmitentArg)
+	}
+	result.Bonds = bonds
+
+	return &result, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spectrec/invest-tools/bond"
+	"github.com/spectrec/invest-tools/moex"
+)
+
+var updateArg = flag.Bool("update", false, "update golden files")
+
+// TestScreenAsOf runs listing as of the past date using iss snapshots from testdata
+// (history doesn't contain coupon periods, so they are restored from bondization)
+func TestScreenAsOf(t *testing.T) {
+	var asOf = date("2024-10-10")
+
+	moex.CacheDir = filepath.Join("testdata", "iss")
+	moex.CacheDate = asOf
+	defer func() {
+		moex.CacheDir = ""
+		moex.CacheDate = time.Time{}
+	}()
+
+	var sc = Screen{
+		Emitents: map[string]*moex.Emitent{
+			"RU000A1000A1": {Title: "ПАО Фикс А", INN: "7701000001"},
+			"RU000A1000B2": {Title: "ПАО Фикс Б", INN: "7701000002"},
+			"RU000A1000C3": {Title: "ПАО Дефолт В", INN: "7701000003"},
+			"RU000A1000C4": {Title: "ПАО Дефолт В", INN: "7701000003"},
+			"RU000A1000D5": {Title: "ПАО Низкий Г", INN: "7701000004"},
+			"RU000A1000E6": {Title: "ПАО Короткий Д", INN: "7701000005"},
+		},
+		DayCount: bond.ActAct,
+		Calendar: moex.NewCalendar(),
+	}
+
+	result, err := sc.run(asOf, nil)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	var dir, _ = ioutil.TempDir("", "listing")
+	defer os.RemoveAll(dir)
+
+	for _, golden := range []struct {
+		path  string
+		store func(path string) error
+	}{
+		{"as-of.json", func(path string) error { return storeJSON(path, result.Bonds) }},
+		{"as-of.rejected", func(path string) error { return storeRejected(path, result.Rejected) }},
+	} {
+		var path = filepath.Join(dir, golden.path)
+		if err := golden.store(path); err != nil {
+			t.Fatalf("can't store `%v': %v", path, err)
+		}
+
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var expectedPath = filepath.Join("testdata", golden.path)
+		if *updateArg {
+			if err = ioutil.WriteFile(expectedPath, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected, err := ioutil.ReadFile(expectedPath)
+		if err != nil {
+			t.Fatalf("can't read golden file (use -update to create it): %v", err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Errorf("`%v' mismatch (use -update to accept changes):\n%s", expectedPath, got)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spectrec/invest-tools/dividend"
+	"github.com/spectrec/invest-tools/financials"
+	"github.com/spectrec/invest-tools/moex"
+)
+
+var sharesArg = flag.Bool("shares", false, "screen shares instead of bonds (market data is joined with financials files by inn)")
+var sharesBoardArg = flag.String("shares-board", "TQBR", "moex board used in shares mode")
+var financialsDirArg = flag.String("financials-dir", "financials/data", "path to directory with company financials (<number>.<ticker>.<inn>.plx)")
+var sortSharesByArg = flag.String("sort-shares-by", "pe", "sort shares by: pe, pb, dividend-yield, roe, debt-ratio")
+
+var maxPEArg = flag.Float64("max-pe", 0, "max P/E, shares with losses are skipped too (0 - disabled)")
+var maxPBArg = flag.Float64("max-pb", 0, "max P/B (0 - disabled)")
+var minDividendYieldArg = flag.Float64("min-dividend-yield", 0, "min trailing 12 months dividend yield percent (0 - disabled)")
+var minROEArg = flag.Float64("min-roe", 0, "min ROE percent (0 - disabled)")
+var maxDebtRatioArg = flag.Float64("max-debt-ratio", 0, "max liabilities to assets ratio (0 - disabled)")
+
+// share skip reasons, the order is used for skip stat output
+const (
+	rejectNoFinancials     = "no financials"
+	rejectHighPE           = "high P/E"
+	rejectHighPB           = "high P/B"
+	rejectLowDividendYield = "low dividend yield"
+	rejectLowROE           = "low ROE"
+	rejectHighDebtRatio    = "high debt ratio"
+)
+
+var shareRejectReasons = []string{
+	rejectBlacklisted,
+	rejectNoFinancials,
+	rejectHighPE,
+	rejectHighPB,
+	rejectLowDividendYield,
+	rejectLowROE,
+	rejectHighDebtRatio,
+}
+
+// Share contains company shares (common and preferred) market data and multiples,
+// multiples are calculated using the last financials year
+type Share struct {
+	Tickers []string `json:"tickers"`
+	Emitent string   `json:"emitent"`
+	INN     string   `json:"inn"`
+	Comment string   `json:"comment"`
+
+	Capitalization float64 `json:"capitalization"`
+	Year           int     `json:"financials_year"`
+
+	PE            float64 `json:"pe"`
+	PB            float64 `json:"pb"`
+	DividendYield float64 `json:"dividend_yield"` // trailing 12 months, percent
+	ROE           float64 `json:"roe"`            // percent
+	DebtRatio     float64 `json:"debt_ratio"`
+
+	Rejection *Rejection `json:"rejection,omitempty"`
+
+	dividends float64 // trailing 12 months dividends of all shares
+}
+
+func (s *Share) String() string {
+	return toJSON(s)
+}
+
+func (s *Share) reject(reason, value, threshold string) {
+	s.Rejection = &Rejection{Reason: reason, Value: value, Threshold: threshold}
+}
+
+func (s *Share) init(r *financials.Report) {
+	var last = r.Last()
+
+	s.Year = r.Years[last]
+	if r.AdjNetIncome[last] != 0 {
+		s.PE = s.Capitalization / r.AdjNetIncome[last]
+	}
+	if r.Equity(last) != 0 {
+		s.PB = s.Capitalization / r.Equity(last)
+	}
+	if s.Capitalization > 0 {
+		s.DividendYield = s.dividends / s.Capitalization * 100.0
+	}
+
+	s.ROE = r.ROE(last)
+	s.DebtRatio = r.DebtRatio(last)
+}
+
+// runShares screens board shares with known financials (market data is always current)
+func runShares(sc *Screen, today time.Time) error {
+	reports, err := financials.LoadDir(*financialsDirArg)
+	if err != nil {
+		return fmt.Errorf("can't load financials: %v", err)
+	}
+
+	emitents, err := moex.DownloadEmitents("shares")
+	if err != nil {
+		return fmt.Errorf("can't download emitents: %v", err)
+	}
+
+	board, err := moex.DownloadShares(*sharesBoardArg)
+	if err != nil {
+		return fmt.Errorf("can't download shares: %v", err)
+	}
+
+	var tickers []string
+	for ticker := range board {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+
+	// common and preferred shares of the same emitent are merged
+	var shares []*Share
+	var inn2share = make(map[string]*Share)
+	for _, ticker := range tickers {
+		var e = emitents[ticker]
+		if e == nil || e.INN == "" {
+			log.Printf("emitent for `%v' not found", ticker)
+			continue
+		}
+
+		s := inn2share[e.INN]
+		if s == nil {
+			s = &Share{Emitent: e.Title, INN: e.INN, Comment: sc.EmitentComment[e.Title]}
+			inn2share[e.INN] = s
+
+			shares = append(shares, s)
+		}
+
+		s.Tickers = append(s.Tickers, ticker)
+		s.Capitalization += board[ticker].Capitalization
+
+		if reports[e.INN] != nil {
+			history, err := moex.DownloadDividends(ticker)
+			if err != nil {
+				return fmt.Errorf("can't download `%v' dividends: %v", ticker, err)
+			}
+
+			var ttm = dividend.Analyze(history, "RUB", board[ticker].Price, today, 1).TrailingYield
+			s.dividends += ttm / 100.0 * board[ticker].Price * board[ticker].IssueSize
+		}
+	}
+
+	var result, rejected []*Share
+	for _, s := range shares {
+		for _, exclude := range sc.ExcludeEmitent {
+			if strings.Contains(s.Emitent, exclude) {
+				s.reject(rejectBlacklisted, s.Emitent, "emitent blacklist: "+exclude)
+				break
+			}
+		}
+
+		var r = reports[s.INN]
+		if s.Rejection == nil && r == nil {
+			s.reject(rejectNoFinancials, s.INN, "file in "+*financialsDirArg)
+		}
+
+		if s.Rejection == nil {
+			s.init(r)
+		}
+
+		if s.Rejection == nil && *maxPEArg > 0 && (s.PE <= 0 || s.PE > *maxPEArg) {
+			s.reject(rejectHighPE, fmt.Sprintf("%.2f", s.PE), fmt.Sprintf("0 .. %.2f", *maxPEArg))
+		}
+		if s.Rejection == nil && *maxPBArg > 0 && (s.PB <= 0 || s.PB > *maxPBArg) {
+			s.reject(rejectHighPB, fmt.Sprintf("%.2f", s.PB), fmt.Sprintf("0 .. %.2f", *maxPBArg))
+		}
+		if s.Rejection == nil && s.DividendYield < *minDividendYieldArg {
+			s.reject(rejectLowDividendYield, fmt.Sprintf("%.2f%%", s.DividendYield), fmt.Sprintf("%.2f%%", *minDividendYieldArg))
+		}
+		if s.Rejection == nil && *minROEArg > 0 && s.ROE < *minROEArg {
+			s.reject(rejectLowROE, fmt.Sprintf("%.2f%%", s.ROE), fmt.Sprintf("%.2f%%", *minROEArg))
+		}
+		if s.Rejection == nil && *maxDebtRatioArg > 0 && s.DebtRatio > *maxDebtRatioArg {
+			s.reject(rejectHighDebtRatio, fmt.Sprintf("%.3f", s.DebtRatio), fmt.Sprintf("%.3f", *maxDebtRatioArg))
+		}
+
+		if s.Rejection != nil {
+			rejected = append(rejected, s)
+			continue
+		}
+
+		result = append(result, s)
+	}
+
+	if err = sortShares(result, *sortSharesByArg); err != nil {
+		return err
+	}
+
+	var skipStat = make(map[string]int)
+	for _, s := range rejected {
+		skipStat[s.Rejection.Reason]++
+	}
+
+	log.Printf("\nskip stat:\n")
+	for _, reason := range shareRejectReasons {
+		log.Printf("\t%v: %v\n", reason, skipStat[reason])
+	}
+	log.Println()
+
+	log.Println("Storing results ...")
+	file, err := os.OpenFile(*outputFileArg, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for i, s := range result {
+		if _, err = fmt.Fprintf(file, "%v: %v\n\n", i, s); err != nil {
+			return fmt.Errorf("can't store results into `%v': %v", *outputFileArg, err)
+		}
+	}
+
+	log.Printf("Results stored into `%s'", *outputFileArg)
+
+	if *rejectedFileArg != "" {
+		f, err := os.OpenFile(*rejectedFileArg, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		for _, s := range rejected {
+			if _, err = fmt.Fprintf(f, "%v\t%v\t%v\t%v\n", strings.Join(s.Tickers, ","), s.INN, s.Emitent, s.Rejection); err != nil {
+				return fmt.Errorf("can't write into `%v': %v", *rejectedFileArg, err)
+			}
+		}
+
+		log.Printf("Rejected shares stored into `%s'", *rejectedFileArg)
+	}
+
+	return nil
+}
+
+// lessMultiple compares multiples ascending, non positive ones (losses, negative equity)
+// are the worst, so they are placed after positive ones
+func lessMultiple(a, b float64) bool {
+	if (a > 0) != (b > 0) {
+		return a > 0
+	}
+
+	return a < b
+}
+
+func sortShares(shares []*Share, by string) error {
+	var less func(a, b *Share) bool
+	switch by {
+	case "pe":
+		less = func(a, b *Share) bool { return lessMultiple(a.PE, b.PE) }
+	case "pb":
+		less = func(a, b *Share) bool { return lessMultiple(a.PB, b.PB) }
+	case "dividend-yield":
+		less = func(a, b *Share) bool { return a.DividendYield > b.DividendYield }
+	case "roe":
+		less = func(a, b *Share) bool { return a.ROE > b.ROE }
+	case "debt-ratio":
+		less = func(a, b *Share) bool { return a.DebtRatio < b.DebtRatio }
+	default:
+		return fmt.Errorf("unknown shares sort `%v'", by)
+	}
+
+	sort.SliceStable(shares, func(i, j int) bool {
+		return less(shares[i], shares[j])
+	})
+
+	return nil
+}
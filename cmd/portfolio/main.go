@@ -0,0 +1,940 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spectrec/invest-tools/cbr"
+	"github.com/spectrec/invest-tools/moex"
+	"github.com/spectrec/invest-tools/portfolio"
+)
+
+var priceCacheArg = flag.String("price-cache", "price.cache", "path to prices cache")
+var priceCacheTTLArg = flag.Duration("price-cache-ttl", 24*time.Hour, "cached prices lifetime (0 - never expire)")
+var formatArg = flag.String("format", "text", "output format: text, json")
+var taxArg = flag.Float64("tax", 13, "income tax percent")
+var cashArg = flag.Float64("cash", 0, "rebalance: money available for purchases")
+var buyOnlyArg = flag.Bool("buy-only", false, "rebalance: don't sell anything, only distribute cash")
+var limitSlippageArg = flag.Float64("limit-slippage", 0.5, "rebalance: limit price deviation from the last price, percent")
+var companyArg = flag.String("company", "", "import: portfolio company (broker) the reports belong to")
+var writeArg = flag.Bool("write", false, "import: update portfolio file using broker reports")
+var monthsArg = flag.Int("months", 12, "forecast: number of months")
+var lowIncomePercentArg = flag.Float64("low-income-percent", 50, "forecast: month is highlighted if its income is less than this percent of average one")
+var ledgerArg = flag.String("ledger", "ledger.csv", "returns, taxes: path to transactions ledger (date,company,operation,id,quantity,price,amount,fee,comment)")
+var historyFromArg = flag.String("history-from", "", "history: start date yyyy-mm-dd (by default: a year ago)")
+var historyTillArg = flag.String("history-till", "", "history: end date yyyy-mm-dd (by default: today)")
+var historyStepArg = flag.String("history-step", "month", "history: step between points: day, week, month")
+var historySourceArg = flag.String("history-source", "portfolio", "history: holdings source: portfolio (current holdings), ledger (reconstructed using `-ledger')")
+var benchmarkArg = flag.String("benchmark", "", "history: benchmark monthly series, e.g. strategy/data/mcftr.txt (`yyyy/mm value' lines, empty - disabled)")
+var riskLimitsArg = flag.String("risk-limits", "", "risk: path to limits json (see portfolio/risk-limits.json), by default only exposures are printed")
+var stressScenariosArg = flag.String("stress-scenarios", "", "stress: path to scenarios json (see portfolio/stress-scenarios.json), by default: rate +300bp, rub -20%, equities -30% and all of them")
+var universeArg = flag.String("universe", "universe.json", "swap: path to listing suitable bonds (see listing `-json-output')")
+var swapSpreadArg = flag.Float64("swap-spread", 0.3, "swap: bid/ask spread lost on both trades, percent")
+var swapCommissionArg = flag.Float64("swap-commission", 0.05, "swap: broker commission of each trade, percent")
+var swapDurationGapArg = flag.Float64("swap-duration-gap", 0.5, "swap: max candidate duration difference, years")
+var swapMinGainArg = flag.Float64("swap-min-gain", 0.5, "swap: min after tax yield gain net of costs, percent points")
+var dividendSourceArg = flag.String("dividend-source", "ttm", "report, stress: stocks dividend yield source if `dividend_yield' is missing: ttm (trailing 12 months), average")
+var dividendYearsArg = flag.Int("dividend-years", 5, "report, stress: number of calendar years used by average dividend yield")
+var navMaxAgeArg = flag.Int("nav-max-age", 45, "nav: warn if fund net asset value is older than this number of days")
+var ratesArg = flag.String("rates", "moex", "currency and metal rates source: moex, cbr")
+var reportCurrencyArg = flag.String("report-currency", "RUB", "report currency: RUB, USD, EUR, CNY, GLD (gold grams), SLV (silver grams)")
+var issCacheArg = flag.String("iss-cache", "", "path to directory for iss responses snapshots (by default: disabled)")
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %v [<options>] [<mode>] <portfolio.json> [<args>]\n\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "Modes:\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  report\tprints allocation by type and expected cash flow (default)\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  rebalance\tprints orders moving portfolio towards asset_weight_plan\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  import\t<report>... compares company assets with broker reports (csv, xlsx, xml)\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  returns\tprints ledger holdings, xirr and time-weighted return\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  taxes\tprints ledger FIFO tax lots, realized profit and yearly ndfl estimate\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  forecast\tprints month by month cash flow forecast using payments schedules\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  history\tprints csv with portfolio value, drawdown and benchmark at past dates\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  risk\tprints exposures by emitent, company, type and sector and limits breaches\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  bonds\tprints held bonds market ytm, duration and their value weighted averages\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  swap\tproposes listing bonds of similar duration and better yield instead of held ones\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  nav\tprints funds premium/discount to net asset value, payout yield on nav and nav history\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  crowd\tprints crowdlending accounts value and net yield after expected defaults and tax\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  stress\tprints value loss and income change under rate, fx and equity shocks\n\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "Options:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	var mode, path, args = "report", flag.Arg(0), []string{}
+	switch flag.NArg() {
+	case 0:
+		flag.Usage()
+		os.Exit(1)
+	case 1:
+	default:
+		mode, path, args = flag.Arg(0), flag.Arg(1), flag.Args()[2:]
+	}
+
+	if *formatArg != "text" && *formatArg != "json" {
+		log.Fatalf("unknown format `%v'", *formatArg)
+	}
+
+	moex.CacheDir = *issCacheArg
+
+	p, err := portfolio.Load(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var tax = *taxArg / 100.0
+
+	// rates are downloaded only by modes converting values between currencies
+	switch mode {
+	case "import", "nav", "crowd":
+	default:
+		if err = setCurrency(p); err != nil {
+			log.Fatalf("can't set report currency: %v", err)
+		}
+	}
+	if mode != "import" {
+		if err = p.LoadLoanBooks(filepath.Dir(path), moex.Today()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch mode {
+	case "report":
+		err = runReport(p, tax)
+	case "rebalance":
+		err = runRebalance(p, tax)
+	case "import":
+		err = runImport(p, path, args)
+	case "returns":
+		err = runReturns(p)
+	case "taxes":
+		err = runTaxes(p)
+	case "forecast":
+		err = runForecast(p, tax)
+	case "history":
+		err = runHistory(p)
+	case "risk":
+		err = runRisk(p)
+	case "bonds":
+		err = runBonds(p, tax)
+	case "swap":
+		err = runSwap(p, tax)
+	case "nav":
+		err = runNAV(p)
+	case "crowd":
+		err = runCrowd(p, tax)
+	case "stress":
+		err = runStress(p, tax)
+	default:
+		log.Fatalf("unknown mode `%v'", mode)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// setCurrency downloads rates of assets and report currencies
+func setCurrency(p *portfolio.Portfolio) error {
+	var currencies = p.Currencies()
+	if *reportCurrencyArg != portfolio.RUB {
+		currencies = append(currencies, *reportCurrencyArg)
+	}
+
+	var rates = make(map[string]float64)
+	for _, c := range currencies {
+		if _, ok := rates[c]; ok {
+			continue
+		}
+
+		var rate float64
+		var err error
+		switch *ratesArg {
+		case "moex":
+			rate, err = moex.DownloadCurrencyRate(c)
+		case "cbr":
+			if _, ok := cbr.MetalCodes[c]; ok {
+				rate, err = cbr.DownloadMetalPrice(c, moex.Today())
+				break
+			}
+
+			var all map[string]float64
+			if all, err = cbr.DownloadRates(moex.Today()); err == nil {
+				if rate = all[c]; rate == 0 {
+					err = fmt.Errorf("unknown currency `%v'", c)
+				}
+			}
+		default:
+			return fmt.Errorf("unknown rates source `%v'", *ratesArg)
+		}
+		if err != nil {
+			return fmt.Errorf("can't get `%v' rate: %v", c, err)
+		}
+
+		log.Printf("%v rate: %v", c, rate)
+		rates[c] = rate
+	}
+
+	return p.SetCurrency(*reportCurrencyArg, rates)
+}
+
+func fillPrices(p *portfolio.Portfolio) error {
+	cache, err := portfolio.LoadPriceCache(*priceCacheArg, *priceCacheTTLArg)
+	if err != nil {
+		return fmt.Errorf("can't load price cache: %v", err)
+	}
+	if err = p.FillPrices(cache, time.Now()); err != nil {
+		return fmt.Errorf("can't fetch prices: %v", err)
+	}
+	if err = cache.Store(); err != nil {
+		return fmt.Errorf("can't store price cache: %v", err)
+	}
+
+	return nil
+}
+
+// fillDividends calculates stocks dividend yields using history, prices must be filled
+func fillDividends(p *portfolio.Portfolio) error {
+	warnings, err := p.FillDividends(*dividendSourceArg, *dividendYearsArg, moex.Today())
+	for _, warn := range warnings {
+		log.Printf("WARNING: %v", warn)
+	}
+
+	return err
+}
+
+func runReport(p *portfolio.Portfolio, tax float64) error {
+	if err := fillPrices(p); err != nil {
+		return err
+	}
+	if err := fillDividends(p); err != nil {
+		return err
+	}
+
+	var r = p.Report(tax, moex.Today())
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, r)
+	}
+
+	return printReport(os.Stdout, r)
+}
+
+func runRebalance(p *portfolio.Portfolio, tax float64) error {
+	if err := fillPrices(p); err != nil {
+		return err
+	}
+
+	r, err := p.Rebalance(portfolio.RebalanceOptions{
+		Cash:     *cashArg,
+		BuyOnly:  *buyOnlyArg,
+		Tax:      tax,
+		Slippage: *limitSlippageArg / 100.0,
+	})
+	if err != nil {
+		return fmt.Errorf("can't rebalance: %v", err)
+	}
+
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, r)
+	}
+
+	return printRebalance(os.Stdout, r)
+}
+
+func runImport(p *portfolio.Portfolio, path string, reports []string) error {
+	if *companyArg == "" {
+		return fmt.Errorf("`-company' is required for import")
+	}
+	if len(reports) == 0 {
+		return fmt.Errorf("broker reports are required for import")
+	}
+
+	var positions []*portfolio.Position
+	for _, report := range reports {
+		list, err := portfolio.ImportReport(report)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("%v positions found in `%v'", len(list), report)
+		positions = append(positions, list...)
+	}
+
+	var diff = p.Reconcile(*companyArg, positions)
+	if *formatArg == "json" {
+		if err := printJSON(os.Stdout, diff); err != nil {
+			return err
+		}
+	} else {
+		printDifferences(os.Stdout, diff)
+	}
+
+	if !*writeArg || len(diff) == 0 {
+		return nil
+	}
+
+	warnings, err := p.ApplyImport(*companyArg, positions)
+	for _, warn := range warnings {
+		log.Printf("WARNING: %v", warn)
+	}
+	if err != nil {
+		return fmt.Errorf("imported portfolio is invalid: %v", err)
+	}
+
+	if err = p.Store(path); err != nil {
+		return fmt.Errorf("can't store portfolio: %v", err)
+	}
+	log.Printf("Portfolio `%v' updated", path)
+
+	return nil
+}
+
+func runReturns(p *portfolio.Portfolio) error {
+	ledger, err := portfolio.LoadLedger(*ledgerArg)
+	if err != nil {
+		return fmt.Errorf("can't load ledger: %v", err)
+	}
+
+	if err = fillPrices(p); err != nil {
+		return err
+	}
+
+	var today = moex.Today()
+	var from = today
+	if len(ledger.Transactions) != 0 {
+		from = ledger.Transactions[0].Date
+	}
+
+	r, err := ledger.Returns(p.Valuer(), p.PastValuer(from, today), today)
+	if err != nil {
+		return err
+	}
+
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, r)
+	}
+
+	return printReturns(os.Stdout, r)
+}
+
+func runTaxes(p *portfolio.Portfolio) error {
+	ledger, err := portfolio.LoadLedger(*ledgerArg)
+	if err != nil {
+		return fmt.Errorf("can't load ledger: %v", err)
+	}
+
+	if err = fillPrices(p); err != nil {
+		return err
+	}
+
+	var r = ledger.Taxes(p.Valuer(), p.IIS(), moex.Today())
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, r)
+	}
+
+	return printTaxes(os.Stdout, r)
+}
+
+func printTaxes(w io.Writer, r *portfolio.TaxReport) error {
+	for _, warn := range r.Warnings {
+		fmt.Fprintf(w, "WARNING: %v\n", warn)
+	}
+
+	fmt.Fprintf(w, "\nOpen lots:\n")
+	for _, l := range r.Lots {
+		var longTerm string
+		if l.LongTerm {
+			longTerm = ", long-term"
+		}
+
+		fmt.Fprintf(w, "\t%v %-15s %v, cost: %.2f, value: %.2f, unrealized: %.2f%v (%v)\n",
+			l.Date.Format(moex.DateFormat), l.ID, l.Quantity, l.Cost, l.Value, l.Unrealized, longTerm, l.Company)
+	}
+
+	fmt.Fprintf(w, "\nRealized:\n")
+	for _, s := range r.Realized {
+		fmt.Fprintf(w, "\t%v %-15s %v (bought: %v), cost: %.2f, proceeds: %.2f, gain: %.2f, exempt: %.2f (%v)\n",
+			s.SellDate.Format(moex.DateFormat), s.ID, s.Quantity, s.BuyDate.Format(moex.DateFormat), s.Cost, s.Proceeds, s.Gain, s.Exempt, s.Company)
+	}
+
+	fmt.Fprintf(w, "\nNDFL:\n")
+	for _, y := range r.Years {
+		var iis string
+		if y.IIS {
+			iis = " (iis, due at closing)"
+		}
+
+		fmt.Fprintf(w, "\t%v %-15s gain: %.2f, exempt: %.2f, fees: %.2f, coupons: %.2f, dividends: %.2f, base: %.2f, tax: %.2f%v\n",
+			y.Year, y.Company, y.Gain, y.Exempt, y.Fees, y.Coupons, y.Dividends, y.Base, y.Tax, iis)
+	}
+
+	return nil
+}
+
+func runForecast(p *portfolio.Portfolio, tax float64) error {
+	f, err := p.Forecast(portfolio.ForecastOptions{
+		Months:    *monthsArg,
+		Tax:       tax,
+		LowIncome: *lowIncomePercentArg / 100.0,
+	}, moex.Today())
+	if err != nil {
+		return err
+	}
+
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, f)
+	}
+
+	return printForecast(os.Stdout, f)
+}
+
+func printForecast(w io.Writer, f *portfolio.Forecast) error {
+	fmt.Fprintf(w, "\nIncome: %.2f, monthly average: %.2f\n", f.Income, f.AverageIncome)
+	for _, m := range f.Months {
+		var notes []string
+		if m.Low {
+			notes = append(notes, "LOW INCOME")
+		}
+		if m.Redemptions > 0 {
+			notes = append(notes, fmt.Sprintf("redemptions: %.2f", m.Redemptions))
+		}
+		if m.Offers > 0 {
+			notes = append(notes, fmt.Sprintf("offers: %v", m.Offers))
+		}
+
+		var note string
+		if len(notes) != 0 {
+			note = " [" + strings.Join(notes, ", ") + "]"
+		}
+
+		fmt.Fprintf(w, "\t%v: %12.2f (coupons: %.2f, dividends: %.2f)%v\n", m.Month, m.Income, m.Coupons, m.Dividends, note)
+	}
+
+	if len(f.Redemptions) != 0 {
+		fmt.Fprintf(w, "\nNeeds reinvesting:\n")
+		for _, e := range f.Redemptions {
+			fmt.Fprintf(w, "\t%v %-10s %-15s %.2f %v (%v)\n", e.Date.Format(moex.DateFormat), e.Kind, e.ID, e.Amount, e.Name, e.Company)
+		}
+	}
+
+	for _, warn := range f.Warnings {
+		fmt.Fprintf(w, "WARNING: %v\n", warn)
+	}
+
+	return nil
+}
+
+func parseDateArg(name, value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	date, err := moex.ParseDate(value)
+	if err != nil {
+		return date, fmt.Errorf("invalid `-%v': %v", name, err)
+	}
+
+	return date, nil
+}
+
+func runHistory(p *portfolio.Portfolio) error {
+	var opts = portfolio.HistoryOptions{Step: *historyStepArg}
+
+	var err error
+	if opts.Till, err = parseDateArg("history-till", *historyTillArg, moex.Today()); err != nil {
+		return err
+	}
+	if opts.From, err = parseDateArg("history-from", *historyFromArg, opts.Till.AddDate(-1, 0, 0)); err != nil {
+		return err
+	}
+
+	switch *historySourceArg {
+	case "portfolio":
+	case "ledger":
+		if opts.Ledger, err = portfolio.LoadLedger(*ledgerArg); err != nil {
+			return fmt.Errorf("can't load ledger: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown history source `%v'", *historySourceArg)
+	}
+
+	if *benchmarkArg != "" {
+		if opts.Benchmark, err = portfolio.LoadMonthlySeries(*benchmarkArg); err != nil {
+			return fmt.Errorf("can't load benchmark: %v", err)
+		}
+	}
+
+	if err = fillPrices(p); err != nil {
+		return err
+	}
+
+	h, err := p.History(opts)
+	if err != nil {
+		return err
+	}
+
+	for _, warn := range h.Warnings {
+		log.Printf("WARNING: %v", warn)
+	}
+
+	log.Printf("return: %.2f%% (annual: %.2f%%), max drawdown: %.2f%% (%v .. %v)",
+		h.Return, h.AnnualReturn, h.MaxDrawdown, h.MaxDrawdownPeak.Format(moex.DateFormat), h.MaxDrawdownDate.Format(moex.DateFormat))
+	if *benchmarkArg != "" {
+		log.Printf("benchmark return: %.2f%%, max drawdown: %.2f%%", h.BenchmarkReturn, h.BenchmarkMaxDrawdown)
+	}
+
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, h)
+	}
+
+	return printHistory(os.Stdout, h)
+}
+
+func printHistory(w io.Writer, h *portfolio.History) error {
+	var out = csv.NewWriter(w)
+	if err := out.Write([]string{"date", "value", "flows", "index", "drawdown", "benchmark"}); err != nil {
+		return err
+	}
+
+	for _, p := range h.Points {
+		var benchmark string
+		if p.Benchmark != 0 {
+			benchmark = fmt.Sprintf("%.2f", p.Benchmark)
+		}
+
+		var row = []string{
+			p.Date.Format(moex.DateFormat),
+			fmt.Sprintf("%.2f", p.Value),
+			fmt.Sprintf("%.2f", p.Flows),
+			fmt.Sprintf("%.2f", p.Index),
+			fmt.Sprintf("%.2f", p.Drawdown),
+			benchmark,
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+
+	return out.Error()
+}
+
+func runRisk(p *portfolio.Portfolio) error {
+	var limits = &portfolio.RiskLimits{}
+	if *riskLimitsArg != "" {
+		var err error
+		if limits, err = portfolio.LoadRiskLimits(*riskLimitsArg); err != nil {
+			return fmt.Errorf("can't load risk limits: %v", err)
+		}
+	}
+
+	if err := fillPrices(p); err != nil {
+		return err
+	}
+
+	var r = p.Risk(limits)
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, r)
+	}
+
+	return printRisk(os.Stdout, r)
+}
+
+func printRisk(w io.Writer, r *portfolio.RiskReport) error {
+	var kind string
+	for _, e := range r.Exposures {
+		if e.Kind != kind {
+			kind = e.Kind
+			fmt.Fprintf(w, "\nBy %v:\n", kind)
+		}
+
+		var breach string
+		if e.Breach {
+			breach = fmt.Sprintf(" BREACH (excess: %.2f)", e.Excess)
+		}
+
+		var title = e.Key
+		if e.Title != "" {
+			title = fmt.Sprintf("%v (%v)", e.Title, e.Key)
+		}
+
+		fmt.Fprintf(w, "\t%-40s %v (%.1f%%), limit: %v%v\n", title, price2str(e.Value), e.Percent, e.Limit, breach)
+	}
+
+	if len(r.Breaches) == 0 {
+		fmt.Fprintf(w, "\nThere are no limits breaches\n")
+		return nil
+	}
+
+	fmt.Fprintf(w, "\nBreaches:\n")
+	for _, e := range r.Breaches {
+		fmt.Fprintf(w, "\t%-8s %v %v: %.2f (%.1f%%), limit: %v, excess: %.2f\n", e.Kind, e.Key, e.Title, e.Value, e.Percent, e.Limit, e.Excess)
+	}
+
+	return nil
+}
+
+func runBonds(p *portfolio.Portfolio, tax float64) error {
+	if err := fillPrices(p); err != nil {
+		return err
+	}
+
+	var r = p.Bonds(tax, moex.Today())
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, r)
+	}
+
+	return printBonds(os.Stdout, r)
+}
+
+func printBonds(w io.Writer, r *portfolio.BondsReport) error {
+	for _, warn := range r.Warnings {
+		fmt.Fprintf(w, "WARNING: %v\n", warn)
+	}
+
+	fmt.Fprintf(w, "\nBonds: %.2f %v, ytm: %.2f%% (net: %.2f%%), duration: %.2f (modified: %.2f), maturity: %.2f years\n",
+		r.Value, r.Currency, r.YTM, r.NetYield, r.Duration, r.ModDuration, r.YearsToMaturity)
+	for _, y := range r.Bonds {
+		var notes []string
+		if !y.FixedCoupon {
+			notes = append(notes, "floating coupon")
+		}
+		if y.Estimated {
+			notes = append(notes, "estimated schedule")
+		}
+
+		var note string
+		if len(notes) != 0 {
+			note = " (" + strings.Join(notes, ", ") + ")"
+		}
+
+		fmt.Fprintf(w, "\t%-15s %-25s %-12s value: %12.2f, ytm: %6.2f%% (%+.2f, net: %6.2f%%), duration: %5.2f, maturity: %v%v\n",
+			y.ID, y.Name, y.Company, y.Value, y.YTM, y.Spread, y.NetYield, y.Duration, y.MaturityDate.Format(moex.DateFormat), note)
+	}
+
+	return nil
+}
+
+func runSwap(p *portfolio.Portfolio, tax float64) error {
+	universe, err := portfolio.LoadUniverse(*universeArg)
+	if err != nil {
+		return fmt.Errorf("can't load bonds universe: %v", err)
+	}
+
+	if err = fillPrices(p); err != nil {
+		return err
+	}
+
+	var r = p.Swaps(universe, portfolio.SwapOptions{
+		Tax:         tax,
+		Spread:      *swapSpreadArg,
+		Commission:  *swapCommissionArg,
+		DurationGap: *swapDurationGapArg,
+		MinGain:     *swapMinGainArg,
+	}, moex.Today())
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, r)
+	}
+
+	return printSwaps(os.Stdout, r)
+}
+
+func printSwaps(w io.Writer, r *portfolio.SwapReport) error {
+	for _, warn := range r.Warnings {
+		fmt.Fprintf(w, "WARNING: %v\n", warn)
+	}
+
+	if len(r.Swaps) == 0 {
+		fmt.Fprintf(w, "\nThere are no profitable swaps\n")
+		return nil
+	}
+
+	fmt.Fprintf(w, "\nSwaps:\n")
+	for _, s := range r.Swaps {
+		var c = s.Candidate
+		fmt.Fprintf(w, "\t%v %v (%v, net yield: %.2f%%, duration: %.2f, rating: %v) -> %v %v (net yield: %.2f%%, duration: %.2f, rating: %v)\n",
+			s.Held.ID, s.Held.Name, s.Held.Company, s.Held.NetYield, s.Held.Duration, s.HeldRating,
+			c.ISIN, c.ShortName, c.YieldToMaturity, c.Duration, c.Rating)
+		fmt.Fprintf(w, "\t\tgain: %.2f%% a year, costs: %.2f %v (sale tax: %.2f)\n", s.Gain, s.Costs, r.Currency, s.SaleTax)
+	}
+
+	return nil
+}
+
+func runNAV(p *portfolio.Portfolio) error {
+	if err := fillPrices(p); err != nil {
+		return err
+	}
+
+	var r = p.NAV(*navMaxAgeArg, moex.Today())
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, r)
+	}
+
+	return printNAV(os.Stdout, r)
+}
+
+func printNAV(w io.Writer, r *portfolio.NAVReport) error {
+	for _, warn := range r.Warnings {
+		fmt.Fprintf(w, "WARNING: %v\n", warn)
+	}
+
+	for _, f := range r.Funds {
+		fmt.Fprintf(w, "\n%v %v (%v):\n", f.ID, f.Name, f.Company)
+		fmt.Fprintf(w, "\tprice: %.2f, nav: %.2f (%v), premium: %+.2f%%, payout yield on nav: %.2f%%, nav change for a year: %+.2f%%\n",
+			f.Price, f.NAV, f.NAVDate.Format(moex.DateFormat), f.Premium, f.PayoutYield, f.NAVChange)
+
+		var points []string
+		for _, h := range f.History {
+			points = append(points, fmt.Sprintf("%v %.2f", h.Date.Format(moex.DateFormat), h.Value))
+		}
+		fmt.Fprintf(w, "\tnav history: %v\n", strings.Join(points, ", "))
+	}
+
+	return nil
+}
+
+func runCrowd(p *portfolio.Portfolio, tax float64) error {
+	var accounts = p.Crowdlending(tax)
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, accounts)
+	}
+
+	return printCrowd(os.Stdout, accounts)
+}
+
+func printCrowd(w io.Writer, accounts []*portfolio.CrowdAccount) error {
+	for _, a := range accounts {
+		var title = a.Company
+		if a.Name != "" {
+			title += " " + a.Name
+		}
+
+		fmt.Fprintf(w, "\n%v:\n", title)
+		if a.Flat {
+			fmt.Fprintf(w, "\tflat dividend, value: %.2f, income: %.2f, net yield: %.2f%% (effective: %.2f%%)\n",
+				a.Value, a.Income, a.NetYield, a.EffectiveYield)
+			continue
+		}
+
+		fmt.Fprintf(w, "\tloans: %v, performing: %.2f, overdue: %.2f, defaulted: %.2f, expected value: %.2f\n",
+			a.Loans, a.Performing, a.Overdue, a.Defaulted, a.Value)
+		fmt.Fprintf(w, "\trate: %.2f%%, expected loss: %.2f%%, income: %.2f, net yield: %.2f%% (effective: %.2f%%), principal to reinvest: %.2f\n",
+			a.Rate, a.Loss, a.Income, a.NetYield, a.EffectiveYield, a.Principal)
+	}
+
+	return nil
+}
+
+func runStress(p *portfolio.Portfolio, tax float64) error {
+	var scenarios = portfolio.DefaultScenarios
+	if *stressScenariosArg != "" {
+		var err error
+		if scenarios, err = portfolio.LoadScenarios(*stressScenariosArg); err != nil {
+			return fmt.Errorf("can't load stress scenarios: %v", err)
+		}
+	}
+
+	if err := fillPrices(p); err != nil {
+		return err
+	}
+	if err := fillDividends(p); err != nil {
+		return err
+	}
+
+	var r = p.Stress(scenarios, tax, moex.Today())
+	if *formatArg == "json" {
+		return printJSON(os.Stdout, r)
+	}
+
+	return printStress(os.Stdout, r)
+}
+
+func printStress(w io.Writer, r *portfolio.StressReport) error {
+	for _, warn := range r.Warnings {
+		fmt.Fprintf(w, "WARNING: %v\n", warn)
+	}
+
+	for _, res := range r.Results {
+		fmt.Fprintf(w, "\n%v:\n", res.Scenario.Name)
+		fmt.Fprintf(w, "\tvalue: %.2f -> %.2f %v, loss: %.2f (%.2f%%)\n", res.Value, res.StressedValue, r.Currency, res.Loss, res.LossPercent)
+
+		var types []string
+		for t, loss := range res.Types {
+			if loss != 0 {
+				types = append(types, t)
+			}
+		}
+		sort.Strings(types)
+
+		for _, t := range types {
+			fmt.Fprintf(w, "\t\t%-15s loss: %.2f\n", t, res.Types[t])
+		}
+
+		fmt.Fprintf(w, "\tyearly income: %.2f -> %.2f, change: %+.2f\n", res.Income, res.StressedIncome, res.IncomeChange)
+	}
+
+	return nil
+}
+
+func xirr2str(r *portfolio.Return) string {
+	if r.XIRR == nil {
+		return "n/a"
+	}
+
+	return fmt.Sprintf("%.2f%%", *r.XIRR)
+}
+
+func printReturns(w io.Writer, r *portfolio.Returns) error {
+	for _, warn := range r.Warnings {
+		fmt.Fprintf(w, "WARNING: %v\n", warn)
+	}
+
+	fmt.Fprintf(w, "\nHoldings:\n")
+	for _, h := range r.Holdings {
+		fmt.Fprintf(w, "\t%-15s %v (%v)\n", h.ID, h.Quantity, h.Company)
+	}
+
+	var print = func(title string, r *portfolio.Return) {
+		fmt.Fprintf(w, "\t%-30s invested: %.2f, received: %.2f, value: %.2f, profit: %.2f, xirr: %v\n",
+			title, r.Invested, r.Received, r.Value, r.Profit, xirr2str(r))
+	}
+
+	fmt.Fprintf(w, "\nAssets:\n")
+	for _, a := range r.Assets {
+		print(a.ID+" ("+a.Company+")", a)
+	}
+
+	fmt.Fprintf(w, "\nCompanies:\n")
+	for _, c := range r.Companies {
+		print(c.Company, c)
+	}
+
+	fmt.Fprintf(w, "\nTotal:\n")
+	print("portfolio", r.Total)
+	fmt.Fprintf(w, "\ttime-weighted return: %.2f%% (annualized: %.2f%%)\n", r.TWR, r.TWRAnnualized)
+
+	return nil
+}
+
+func printDifferences(w io.Writer, diff []*portfolio.Difference) {
+	if len(diff) == 0 {
+		fmt.Fprintf(w, "Portfolio matches broker reports\n")
+		return
+	}
+
+	for _, d := range diff {
+		fmt.Fprintf(w, "%-9s %-15s %-15s have: %v, imported: %v %v\n", d.Status, d.ID, d.Type, d.Have, d.Imported, d.Name)
+	}
+}
+
+// price2str formats value as `<millions> млн <thousands> тыс'
+func price2str(value float64) string {
+	var result []string
+
+	if v := int64(value / 1e6); v > 0 {
+		result = append(result, fmt.Sprintf("%v млн", v))
+	}
+	if v := int64(math.Mod(value, 1e6) / 1e3); v > 0 {
+		result = append(result, fmt.Sprintf("%v тыс", v))
+	}
+
+	if len(result) == 0 {
+		return "0"
+	}
+
+	return strings.Join(result, " ")
+}
+
+func printReport(w io.Writer, r *portfolio.Report) error {
+	fmt.Fprintf(w, "\nTotal price: %v %v\n", price2str(r.Value), r.Currency)
+	for _, s := range r.Types {
+		var plan string
+		if s.Plan != nil {
+			plan = fmt.Sprintf(", planned: %v (diff: %v, percent: %.1f%%)",
+				price2str(s.Plan.Value), price2str(math.Abs(s.Plan.Diff)), math.Abs(s.Plan.DiffPercent))
+		}
+
+		fmt.Fprintf(w, "\t%-15s: %v (%.1f%%)%v\n", s.Type, price2str(s.Value), s.Percent, plan)
+	}
+
+	var types = append([]*portfolio.TypeStat{}, r.Types...)
+	sort.SliceStable(types, func(i, j int) bool {
+		return types[i].CashFlow > types[j].CashFlow
+	})
+
+	fmt.Fprintf(w, "\nExpected cash flow: %v (yield: %.2f%%), monthly: %v\n",
+		price2str(r.CashFlow), r.Yield, price2str(r.MonthlyCashFlow))
+	for _, s := range types {
+		if s.CashFlow == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "\t%-15s: %v, monthly: %v (cash flow part: %.1f%%, dirty asset yield: %.1f%%, net asset yield: %.1f%%)\n",
+			s.Type, price2str(s.CashFlow), price2str(s.CashFlow/12), s.CashFlowPercent, s.DirtyYield, s.NetYield)
+	}
+
+	if len(r.Dividends) != 0 {
+		fmt.Fprintf(w, "\nDividend yields:\n")
+		for _, d := range r.Dividends {
+			fmt.Fprintf(w, "\t%-15s %-25s %-12s %.2f%% (%v)\n", d.ID, d.Name, d.Company, d.Yield, d.Source)
+		}
+	}
+
+	if len(r.Expired) != 0 {
+		fmt.Fprintf(w, "WARNING: expired %v\n", r.Expired)
+	}
+
+	return nil
+}
+
+func printRebalance(w io.Writer, r *portfolio.Rebalance) error {
+	fmt.Fprintf(w, "\nOrders:\n")
+	for _, o := range r.Orders {
+		var tax string
+		if o.Side == "sell" {
+			tax = fmt.Sprintf(", tax: %.2f", o.Tax)
+			if o.UnknownAvgPrice {
+				tax += " (unknown avg price)"
+			}
+		}
+
+		fmt.Fprintf(w, "\t%-4s %-15s %-15s %v lots x %v, limit: %v, amount: %.2f%v (%v)\n",
+			o.Side, o.ID, o.Type, o.Lots, o.LotSize, o.LimitPrice, o.Amount, tax, o.Company)
+	}
+	if len(r.Orders) == 0 {
+		fmt.Fprintf(w, "\tnothing to do\n")
+	}
+
+	fmt.Fprintf(w, "\nTypes:\n")
+	for _, t := range r.Types {
+		fmt.Fprintf(w, "\t%-15s: %v -> %v (target: %v)\n", t.Type, price2str(t.Value), price2str(t.After), price2str(t.Target))
+	}
+
+	fmt.Fprintf(w, "\nCash: %.2f, left: %.2f, tax: %.2f\n", r.Cash, r.CashLeft, r.Tax)
+	for _, warn := range r.Warnings {
+		fmt.Fprintf(w, "WARNING: %v\n", warn)
+	}
+
+	return nil
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	var enc = json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+
+	return enc.Encode(v)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+var boardArg = flag.String("board", "TQBR", "moex board")
+var sortArg = flag.String("sort", "cap", "sort by: cap, free-float-cap, ticker, name")
+var formatArg = flag.String("format", "text", "output format: text, csv, json")
+var sharesInfoArg = flag.String("shares-info", "", "path to csv file (ticker,free_float_percent,sector), contains free-float and sector info")
+var issCacheArg = flag.String("iss-cache", "", "path to directory for iss responses snapshots (by default: disabled)")
+
+// Company contains all its shares (common and preferred) traded at the board
+type Company struct {
+	Tickers    []string `json:"tickers"`
+	ISIN       string   `json:"isin"`
+	INN        string   `json:"inn"`
+	Name       string   `json:"name"`
+	ShareCount float64  `json:"share_count"`
+
+	Capitalization          float64 `json:"capitalization"`
+	FreeFloat               float64 `json:"free_float"` // percent
+	FreeFloatCapitalization float64 `json:"free_float_capitalization"`
+	Sector                  string  `json:"sector"`
+
+	hasCommon bool
+}
+
+type shareInfo struct {
+	FreeFloat float64 // percent
+	Sector    string
+}
+
+func main() {
+	flag.Parse()
+
+	moex.CacheDir = *issCacheArg
+
+	var info = make(map[string]*shareInfo)
+	if *sharesInfoArg != "" {
+		var err error
+		if info, err = loadSharesInfo(*sharesInfoArg); err != nil {
+			log.Fatalf("can't load shares info: %v", err)
+		}
+	}
+
+	emitents, err := moex.DownloadEmitents("shares")
+	if err != nil {
+		log.Fatalf("can't download emitents: %v", err)
+	}
+
+	shares, err := moex.DownloadShares(*boardArg)
+	if err != nil {
+		log.Fatalf("can't download shares: %v", err)
+	}
+
+	var companies = groupShares(shares, emitents, info)
+	if err = sortCompanies(companies, *sortArg); err != nil {
+		log.Fatal(err)
+	}
+
+	switch *formatArg {
+	case "text":
+		err = printText(os.Stdout, companies)
+	case "csv":
+		err = printCSV(os.Stdout, companies)
+	case "json":
+		err = printJSON(os.Stdout, companies)
+	default:
+		err = fmt.Errorf("unknown format `%v'", *formatArg)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// groupShares merges common and preferred shares of the same emitent (inn), preferred share
+// ticker is not always common ticker with `P' suffix, so it is used only when inn is unknown
+func groupShares(shares map[string]*moex.Share, emitents map[string]*moex.Emitent, info map[string]*shareInfo) []*Company {
+	var key2company = make(map[string]*Company)
+
+	// common shares first, so preferred ones without inn could be found by ticker
+	var tickers []string
+	for ticker := range shares {
+		tickers = append(tickers, ticker)
+	}
+	sort.Slice(tickers, func(i, j int) bool {
+		if shares[tickers[i]].IsPreferred() != shares[tickers[j]].IsPreferred() {
+			return !shares[tickers[i]].IsPreferred()
+		}
+
+		return tickers[i] < tickers[j]
+	})
+
+	var result []*Company
+	for _, ticker := range tickers {
+		var s = shares[ticker]
+
+		var inn string
+		if e := emitents[ticker]; e != nil {
+			inn = e.INN
+		}
+
+		var key = "inn: " + inn
+		if inn == "" {
+			key = "ticker: " + strings.TrimSuffix(ticker, "P")
+			if !s.IsPreferred() {
+				key = "ticker: " + ticker
+			}
+		}
+
+		c := key2company[key]
+		if c == nil {
+			c = &Company{INN: inn}
+			key2company[key] = c
+
+			result = append(result, c)
+		}
+
+		c.Tickers = append(c.Tickers, ticker)
+		c.ShareCount += s.IssueSize
+		c.Capitalization += s.Capitalization
+
+		if !c.hasCommon {
+			c.ISIN = s.ISIN
+			c.Name = s.Name
+			c.hasCommon = !s.IsPreferred()
+		}
+
+		if i := info[ticker]; i != nil {
+			c.FreeFloatCapitalization += s.Capitalization * i.FreeFloat / 100.0
+			if c.Sector == "" {
+				c.Sector = i.Sector
+			}
+		}
+	}
+
+	for _, c := range result {
+		if c.Capitalization > 0 {
+			c.FreeFloat = c.FreeFloatCapitalization / c.Capitalization * 100.0
+		}
+	}
+
+	return result
+}
+
+func sortCompanies(companies []*Company, by string) error {
+	var less func(a, b *Company) bool
+	switch by {
+	case "cap":
+		less = func(a, b *Company) bool { return a.Capitalization > b.Capitalization }
+	case "free-float-cap":
+		less = func(a, b *Company) bool { return a.FreeFloatCapitalization > b.FreeFloatCapitalization }
+	case "ticker":
+		less = func(a, b *Company) bool { return a.Tickers[0] < b.Tickers[0] }
+	case "name":
+		less = func(a, b *Company) bool { return a.Name < b.Name }
+	default:
+		return fmt.Errorf("unknown sort `%v'", by)
+	}
+
+	sort.SliceStable(companies, func(i, j int) bool {
+		if less(companies[i], companies[j]) {
+			return true
+		}
+		if less(companies[j], companies[i]) {
+			return false
+		}
+
+		return companies[i].Tickers[0] < companies[j].Tickers[0]
+	})
+
+	return nil
+}
+
+// loadSharesInfo parses csv file: `ticker,free_float_percent,sector'
+func loadSharesInfo(path string) (map[string]*shareInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open file `%v': %v", path, err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse csv file: %v", err)
+	}
+
+	var result = make(map[string]*shareInfo)
+	for i, line := range lines {
+		if len(line) != 3 {
+			return nil, fmt.Errorf("line `%v': expected 3 fields (%+v)", i, line)
+		}
+
+		ff, err := strconv.ParseFloat(line[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line `%v': broken free-float `%v': %v", i, line[1], err)
+		}
+
+		result[line[0]] = &shareInfo{FreeFloat: ff, Sector: line[2]}
+	}
+
+	return result, nil
+}
+
+// num2str formats number with `_' as thousands separator
+func num2str(num float64) string {
+	var s = strconv.FormatFloat(math.Round(num), 'f', 0, 64)
+
+	var parts []string
+	for len(s) > 3 {
+		parts = append([]string{s[len(s)-3:]}, parts...)
+		s = s[:len(s)-3]
+	}
+
+	return strings.Join(append([]string{s}, parts...), "_")
+}
+
+func printText(w io.Writer, companies []*Company) error {
+	for _, c := range companies {
+		var inn = c.INN
+		if inn == "" {
+			inn = "<undef>"
+		}
+
+		_, err := fmt.Fprintf(w, "ticker: %-12s isin: %-14s inn: %-12s cnt: %18s  cap: %18s  ff: %5.1f%%  sector: %-20s  name: %s\n",
+			strings.Join(c.Tickers, ","), c.ISIN, inn, num2str(c.ShareCount), num2str(c.Capitalization), c.FreeFloat, c.Sector, c.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printCSV(w io.Writer, companies []*Company) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"tickers", "isin", "inn", "name", "share_count", "capitalization", "free_float", "free_float_capitalization", "sector"})
+
+	for _, c := range companies {
+		writer.Write([]string{
+			strings.Join(c.Tickers, " "),
+			c.ISIN,
+			c.INN,
+			c.Name,
+			strconv.FormatFloat(c.ShareCount, 'f', 0, 64),
+			strconv.FormatFloat(c.Capitalization, 'f', 0, 64),
+			strconv.FormatFloat(c.FreeFloat, 'f', 2, 64),
+			strconv.FormatFloat(c.FreeFloatCapitalization, 'f', 0, 64),
+			c.Sector,
+		})
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func printJSON(w io.Writer, companies []*Company) error {
+	data, err := json.MarshalIndent(companies, "", "\t")
+	if err != nil {
+		return fmt.Errorf("can't encode result: %v", err)
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
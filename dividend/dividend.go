@@ -0,0 +1,78 @@
+// Package dividend calculates dividend yield metrics using dividend history
+package dividend
+
+import (
+	"math"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// Stats describes share dividends, yields are calculated using the current price
+type Stats struct {
+	TrailingYield    float64   `json:"trailing_yield"`     // last 12 months, percent
+	AverageYield     float64   `json:"average_yield"`      // average over the years, percent
+	Stability        float64   `json:"stability"`          // part of the years with payments, percent
+	GrowthRate       float64   `json:"growth_rate"`        // annual dividends growth (cagr), percent
+	YearsWithoutCuts int       `json:"years_without_cuts"` // annual dividends didn't decrease
+	LastPayment      time.Time `json:"last_payment"`
+
+	Annual map[int]float64 `json:"annual"` // annual dividends per share by year
+}
+
+// Analyze calculates dividend stats at `today' using `years' full calendar years before it,
+// payments in currency other than `currency' are ignored
+func Analyze(history []moex.Dividend, currency string, price float64, today time.Time, years int) Stats {
+	var s = Stats{Annual: make(map[int]float64)}
+
+	var lastYear = today.Year() - 1
+	var firstYear = lastYear - years + 1
+
+	var trailing float64
+	for _, d := range history {
+		if d.Currency != currency || d.Date.After(today) {
+			continue
+		}
+
+		s.LastPayment = d.Date
+		s.Annual[d.Date.Year()] += d.Value
+
+		if d.Date.After(today.AddDate(-1, 0, 0)) {
+			trailing += d.Value
+		}
+	}
+
+	if price <= 0 || years <= 0 {
+		return s
+	}
+
+	s.TrailingYield = trailing / price * 100.0
+
+	var total float64
+	var paid int
+	var first, last float64
+	var firstPaidYear = -1
+	for y := firstYear; y <= lastYear; y++ {
+		total += s.Annual[y]
+		if s.Annual[y] > 0 {
+			paid++
+			if firstPaidYear < 0 {
+				firstPaidYear, first = y, s.Annual[y]
+			}
+		}
+	}
+	last = s.Annual[lastYear]
+
+	s.AverageYield = total / float64(years) / price * 100.0
+	s.Stability = float64(paid) / float64(years) * 100.0
+
+	if firstPaidYear >= 0 && firstPaidYear < lastYear {
+		s.GrowthRate = (math.Pow(last/first, 1.0/float64(lastYear-firstPaidYear)) - 1) * 100.0
+	}
+
+	for y := lastYear; y > firstYear && s.Annual[y] > 0 && s.Annual[y] >= s.Annual[y-1]; y-- {
+		s.YearsWithoutCuts++
+	}
+
+	return s
+}
@@ -0,0 +1,192 @@
+// Package financials loads company financial reports from perl data files
+// (see financials.plx and data/template.plx)
+package financials
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Report contains company annual financials, all slices are indexed by `Years'
+type Report struct {
+	Ticker string
+	INN    string
+
+	Years      []int
+	StockCount []float64
+
+	Assets      []float64
+	Liabilities []float64
+
+	Revenue          []float64
+	OperatingIncome  []float64
+	InterestExpenses []float64
+	NetIncome        []float64
+	AdjNetIncome     []float64 // equals to net income when not specified
+
+	NetOperationCF []float64
+	NetInvestingCF []float64
+	Dividends      []float64
+}
+
+// Last returns index of the last reported year
+func (r *Report) Last() int {
+	return len(r.Years) - 1
+}
+
+// Equity returns assets minus liabilities at year `i'
+func (r *Report) Equity(i int) float64 {
+	return r.Assets[i] - r.Liabilities[i]
+}
+
+// ROE returns adjusted net income divided by previous year equity (percent)
+func (r *Report) ROE(i int) float64 {
+	if i == 0 || r.Equity(i-1) == 0 {
+		return 0
+	}
+
+	return r.AdjNetIncome[i] / r.Equity(i-1) * 100.0
+}
+
+// DebtRatio returns liabilities divided by assets
+func (r *Report) DebtRatio(i int) float64 {
+	if r.Assets[i] == 0 {
+		return 0
+	}
+
+	return r.Liabilities[i] / r.Assets[i]
+}
+
+// Parse decodes company file content
+func Parse(data string) (*Report, error) {
+	v, err := parsePerl(data)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("hash expected")
+	}
+
+	years, err := numbers(root, "years")
+	if err != nil {
+		return nil, err
+	}
+	if len(years) == 0 {
+		return nil, fmt.Errorf("`years' is empty")
+	}
+
+	var r = Report{}
+	for _, y := range years {
+		r.Years = append(r.Years, int(y))
+	}
+
+	var fields = []struct {
+		path []string
+		dst  *[]float64
+	}{
+		{[]string{"stock_count"}, &r.StockCount},
+		{[]string{"balance", "assets"}, &r.Assets},
+		{[]string{"balance", "liabilities"}, &r.Liabilities},
+		{[]string{"income", "revenue"}, &r.Revenue},
+		{[]string{"income", "operating_income"}, &r.OperatingIncome},
+		{[]string{"income", "interest_expences"}, &r.InterestExpenses},
+		{[]string{"income", "net_income"}, &r.NetIncome},
+		{[]string{"income", "adj_net_income"}, &r.AdjNetIncome},
+		{[]string{"cache_flow", "net_operation_cf"}, &r.NetOperationCF},
+		{[]string{"cache_flow", "net_investing_cf"}, &r.NetInvestingCF},
+		{[]string{"cache_flow", "dividends"}, &r.Dividends},
+	}
+	for _, f := range fields {
+		var hash = root
+		for _, key := range f.path[:len(f.path)-1] {
+			if hash, ok = hash[key].(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("`%v' hash expected", key)
+			}
+		}
+
+		var name = strings.Join(f.path, ".")
+		if *f.dst, err = numbers(hash, f.path[len(f.path)-1]); err != nil {
+			return nil, fmt.Errorf("`%v': %v", name, err)
+		}
+		if len(*f.dst) != len(r.Years) {
+			return nil, fmt.Errorf("`%v' contains %v values, expected %v (years count)", name, len(*f.dst), len(r.Years))
+		}
+	}
+
+	// undef values of adjusted net income are replaced by net income
+	adj, _ := root["income"].(map[string]interface{})["adj_net_income"].([]interface{})
+	for i, v := range adj {
+		if v == nil {
+			r.AdjNetIncome[i] = r.NetIncome[i]
+		}
+	}
+
+	return &r, nil
+}
+
+// numbers returns array of numbers, undef values are replaced by zero
+func numbers(hash map[string]interface{}, key string) ([]float64, error) {
+	list, ok := hash[key].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("`%v' array expected", key)
+	}
+
+	var result []float64
+	for _, v := range list {
+		switch n := v.(type) {
+		case float64:
+			result = append(result, n)
+		case nil:
+			result = append(result, 0)
+		default:
+			return nil, fmt.Errorf("`%v' contains non numeric value `%v'", key, v)
+		}
+	}
+
+	return result, nil
+}
+
+// LoadFile loads company file, its name must be `<number>.<ticker>.<inn>.plx'
+func LoadFile(path string) (*Report, error) {
+	parts := strings.Split(filepath.Base(path), ".")
+	if len(parts) != 4 || parts[3] != "plx" {
+		return nil, fmt.Errorf("bad file name `%v' (expected: `<number>.<ticker>.<inn>.plx')", path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read `%v': %v", path, err)
+	}
+
+	r, err := Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("can't parse `%v': %v", path, err)
+	}
+
+	r.Ticker, r.INN = parts[1], parts[2]
+	return r, nil
+}
+
+// LoadDir loads all company files from directory by inn (template is skipped)
+func LoadDir(dir string) (map[string]*Report, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.*.*.plx"))
+	if err != nil {
+		return nil, err
+	}
+
+	var result = make(map[string]*Report)
+	for _, path := range paths {
+		r, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		result[r.INN] = r
+	}
+
+	return result, nil
+}
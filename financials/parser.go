@@ -0,0 +1,305 @@
+package financials
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// parser decodes perl data structures used by company files: hashes, arrays,
+// strings, `undef' and arithmetic expressions with numbers (e.g. `1_759.4*1e9')
+type parser struct {
+	data []rune
+	pos  int
+}
+
+func parsePerl(data string) (interface{}, error) {
+	var p = parser{data: []rune(data)}
+
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.skipSpaces(); p.pos < len(p.data) {
+		return nil, p.errorf("unexpected data after the value")
+	}
+
+	return v, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	var line = 1 + strings.Count(string(p.data[:p.pos]), "\n")
+	return fmt.Errorf("line %v: %v", line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.data) {
+		switch {
+		case unicode.IsSpace(p.data[p.pos]):
+			p.pos++
+		case p.data[p.pos] == '#':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next non space character (0 at the end of data)
+func (p *parser) peek() rune {
+	if p.skipSpaces(); p.pos < len(p.data) {
+		return p.data[p.pos]
+	}
+
+	return 0
+}
+
+func (p *parser) expect(s string) error {
+	p.skipSpaces()
+	if !strings.HasPrefix(string(p.data[p.pos:]), s) {
+		return p.errorf("`%v' expected", s)
+	}
+
+	p.pos += len([]rune(s))
+	return nil
+}
+
+func (p *parser) value() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '{':
+		return p.hash()
+	case c == '[':
+		return p.array()
+	case c == '"' || c == '\'':
+		return p.str()
+	case unicode.IsLetter(c):
+		word := p.word()
+		if word != "undef" {
+			return nil, p.errorf("unexpected bareword `%v'", word)
+		}
+
+		return nil, nil
+	}
+
+	return p.expr()
+}
+
+func (p *parser) hash() (map[string]interface{}, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var result = make(map[string]interface{})
+	for p.peek() != '}' {
+		var key string
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			s, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+
+			key = s
+		case unicode.IsLetter(c) || c == '_':
+			key = p.word()
+		default:
+			return nil, p.errorf("hash key expected")
+		}
+
+		if err := p.expect("=>"); err != nil {
+			return nil, err
+		}
+
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		result[key] = v
+
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (p *parser) array() ([]interface{}, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+
+	var result = make([]interface{}, 0)
+	for p.peek() != ']' {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (p *parser) word() string {
+	var start = p.pos
+	for p.pos < len(p.data) && (unicode.IsLetter(p.data[p.pos]) || unicode.IsDigit(p.data[p.pos]) || p.data[p.pos] == '_') {
+		p.pos++
+	}
+
+	return string(p.data[start:p.pos])
+}
+
+// str parses quoted string, escape sequences are kept as is
+func (p *parser) str() (string, error) {
+	var quote = p.peek()
+	p.pos++
+
+	var start = p.pos
+	for p.pos < len(p.data) && p.data[p.pos] != quote {
+		if p.data[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.data) {
+		return "", p.errorf("unterminated string")
+	}
+
+	p.pos++
+	return string(p.data[start : p.pos-1]), nil
+}
+
+func (p *parser) expr() (float64, error) {
+	result, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			v, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			result += v
+		case '-':
+			p.pos++
+			v, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			result -= v
+		default:
+			return result, nil
+		}
+	}
+}
+
+func (p *parser) term() (float64, error) {
+	result, err := p.factor()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		switch p.peek() {
+		case '*':
+			p.pos++
+			v, err := p.factor()
+			if err != nil {
+				return 0, err
+			}
+			result *= v
+		case '/':
+			p.pos++
+			v, err := p.factor()
+			if err != nil {
+				return 0, err
+			}
+			if v == 0 {
+				return 0, p.errorf("division by zero")
+			}
+			result /= v
+		default:
+			return result, nil
+		}
+	}
+}
+
+func (p *parser) factor() (float64, error) {
+	switch c := p.peek(); {
+	case c == '-':
+		p.pos++
+		v, err := p.factor()
+		return -v, err
+	case c == '+':
+		p.pos++
+		return p.factor()
+	case c == '(':
+		p.pos++
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		if err = p.expect(")"); err != nil {
+			return 0, err
+		}
+
+		return v, nil
+	case unicode.IsDigit(c) || c == '.':
+		return p.number()
+	}
+
+	return 0, p.errorf("number expected")
+}
+
+func (p *parser) number() (float64, error) {
+	var start = p.pos
+	for p.pos < len(p.data) {
+		var c = p.data[p.pos]
+		if unicode.IsDigit(c) || c == '.' || c == '_' {
+			p.pos++
+			continue
+		}
+
+		// exponent with optional sign
+		if (c == 'e' || c == 'E') && p.pos+1 < len(p.data) {
+			p.pos++
+			if p.data[p.pos] == '-' || p.data[p.pos] == '+' {
+				p.pos++
+			}
+			continue
+		}
+
+		break
+	}
+
+	var s = strings.Replace(string(p.data[start:p.pos]), "_", "", -1)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, p.errorf("bad number `%v': %v", s, err)
+	}
+
+	return v, nil
+}
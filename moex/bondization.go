@@ -0,0 +1,91 @@
+package moex
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Payment is a scheduled coupon or amortization payment per bond
+// (value is zero if it isn't known yet)
+type Payment struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+// Bondization is a bond payments schedule
+type Bondization struct {
+	Coupons       []Payment   `json:"coupons"`
+	Amortizations []Payment   `json:"amortizations"` // the last one is the maturity redemption
+	Offers        []time.Time `json:"offers"`
+
+	FixedCoupon    bool `json:"fixed_coupon"`    // all coupon rates are known
+	ConstantCoupon bool `json:"constant_coupon"` // all coupon rates are the same
+}
+
+// parsePayments decodes bondization rows (date, valueprc, value), rows with bad dates are skipped
+func parsePayments(rows [][]interface{}) []Payment {
+	var result []Payment
+	for _, v := range rows {
+		date, err := ParseDate(String(v[0]))
+		if err != nil {
+			continue
+		}
+
+		result = append(result, Payment{Date: date, Value: Float(v[2])})
+	}
+
+	return result
+}
+
+// DownloadBondization returns bond (secid or isin) payments schedule
+func DownloadBondization(secid string) (*Bondization, error) {
+	var amortizationColumns = []string{"amortdate", "valueprc", "value"}
+	var couponsColumns = []string{"coupondate", "valueprc", "value"}
+	var offerColumns = []string{"offerdate", "offerdatestart", "offerdateend", "offertype"}
+
+	url := fmt.Sprintf("http://iss.moex.com/iss/securities/%v/bondization.json?limit=unlimited&iss.meta=off&amortizations.columns=%v&coupons.columns=%v&offers.columns=%v",
+		secid, strings.Join(amortizationColumns, ","), strings.Join(couponsColumns, ","), strings.Join(offerColumns, ","))
+
+	var response struct {
+		Amortizations struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"amortizations"`
+		Coupons struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"coupons"`
+		Offers struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"offers"`
+	}
+	if err := Get(url, &response); err != nil {
+		return nil, err
+	}
+
+	var b = &Bondization{
+		Coupons:       parsePayments(response.Coupons.Data),
+		Amortizations: parsePayments(response.Amortizations.Data),
+	}
+
+	for _, v := range response.Offers.Data {
+		if date, err := ParseDate(String(v[0])); err == nil {
+			b.Offers = append(b.Offers, date)
+		}
+	}
+
+	b.FixedCoupon = true
+	b.ConstantCoupon = true
+	for i := 0; i < len(response.Coupons.Data); i++ {
+		if response.Coupons.Data[i][1] == nil {
+			b.ConstantCoupon = false
+			b.FixedCoupon = false
+			break
+		}
+
+		if i > 0 && response.Coupons.Data[i-1][1] != response.Coupons.Data[i][1] {
+			b.ConstantCoupon = false
+		}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,127 @@
+package moex
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// non trading days repeated every year (`mm-dd'), it is an approximation
+// of the exchange calendar, exact dates should be specified via calendar file
+var yearlyHolidays = []string{
+	"01-01", "01-02", "01-07",
+	"02-23",
+	"03-08",
+	"05-01", "05-09",
+	"06-12",
+	"11-04",
+	"12-31",
+}
+
+// Calendar describes exchange trading days: weekdays except holidays
+// and weekends which are declared as trading days
+type Calendar struct {
+	yearlyHolidays map[string]bool
+	holidays       map[string]bool
+	tradingDays    map[string]bool
+}
+
+// NewCalendar returns calendar with the builtin holidays list
+func NewCalendar() *Calendar {
+	var c = Calendar{
+		yearlyHolidays: make(map[string]bool),
+		holidays:       make(map[string]bool),
+		tradingDays:    make(map[string]bool),
+	}
+
+	for _, d := range yearlyHolidays {
+		c.yearlyHolidays[d] = true
+	}
+
+	return &c
+}
+
+// LoadCalendar returns builtin calendar extended by the file content, file format:
+//
+//	# comment
+//	yyyy-mm-dd    - non trading day
+//	+yyyy-mm-dd   - trading day (when it is a weekend or a builtin holiday)
+func LoadCalendar(path string) (*Calendar, error) {
+	var c = NewCalendar()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open file `%v': %v", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var trading = strings.HasPrefix(line, "+")
+		line = strings.TrimPrefix(line, "+")
+
+		if _, err := ParseDate(line); err != nil {
+			return nil, fmt.Errorf("bad calendar date `%v': %v", line, err)
+		}
+
+		if trading {
+			c.tradingDays[line] = true
+		} else {
+			c.holidays[line] = true
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("calendar scan failed: %v", err)
+	}
+
+	return c, nil
+}
+
+// IsTradingDay checks whether exchange works at `t' day (moscow time)
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	var date = Date(t).Format(DateFormat)
+	if c.tradingDays[date] {
+		return true
+	}
+	if c.holidays[date] || c.yearlyHolidays[date[5:]] {
+		return false
+	}
+
+	switch Date(t).Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+
+	return true
+}
+
+// AddTradingDays returns the date of the `n'-th trading day after `t'
+func (c *Calendar) AddTradingDays(t time.Time, n int) time.Time {
+	var date = Date(t)
+	for n > 0 {
+		date = date.AddDate(0, 0, 1)
+		if c.IsTradingDay(date) {
+			n--
+		}
+	}
+
+	return date
+}
+
+// SettlementDate returns settlement date for the trade made at `t' with T+`lag' mode,
+// trades on non trading days are processed as made on the next trading day
+func (c *Calendar) SettlementDate(t time.Time, lag int) time.Time {
+	var date = Date(t)
+	for !c.IsTradingDay(date) {
+		date = date.AddDate(0, 0, 1)
+	}
+
+	return c.AddTradingDays(date, lag)
+}
@@ -0,0 +1,42 @@
+package moex
+
+import "fmt"
+
+// CurrencyTickers maps currencies (and metals, priced per gram) to rub exchange rates tickers at selt market
+var CurrencyTickers = map[string]string{
+	"USD": "USD000UTSTOM",
+	"EUR": "EUR_RUB__TOM",
+	"CNY": "CNYRUB_TOM",
+	"GLD": "GLDRUB_TOM",
+	"SLV": "SLVRUB_TOM",
+}
+
+// DownloadCurrencyRate returns the previous day rub rate of the `currency'
+func DownloadCurrencyRate(currency string) (float64, error) {
+	ticker, ok := CurrencyTickers[currency]
+	if !ok {
+		return 0, fmt.Errorf("unknown currency `%v'", currency)
+	}
+
+	url := fmt.Sprintf("https://iss.moex.com/iss/engines/currency/markets/selt/boards/CETS/securities/%v.json?iss.meta=off&iss.only=securities&securities.columns=SECID,PREVPRICE", ticker)
+	var response struct {
+		Securities struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"securities"`
+	}
+	if err := Get(url, &response); err != nil {
+		return 0, err
+	}
+
+	for _, v := range response.Securities.Data {
+		if len(v) != 2 {
+			continue
+		}
+
+		if rate := Float(v[1]); rate > 0 {
+			return rate, nil
+		}
+	}
+
+	return 0, fmt.Errorf("rate not found in `%v' response", url)
+}
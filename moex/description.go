@@ -0,0 +1,94 @@
+package moex
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Description contains security parameters
+type Description struct {
+	SecID         string    `json:"secid"`
+	ISIN          string    `json:"isin"`
+	Name          string    `json:"name"`
+	ShortName     string    `json:"short_name"`
+	Type          string    `json:"type"`  // e.g. ofz_bond, common_share, etf_ppif
+	Group         string    `json:"group"` // e.g. stock_bonds, stock_shares, stock_ppif, stock_etf
+	FaceValue     float64   `json:"face_value"`
+	CouponPercent float64   `json:"coupon_percent"`
+	MaturityDate  time.Time `json:"maturity_date"`
+}
+
+// DownloadDescription returns security (secid or isin) parameters
+func DownloadDescription(id string) (*Description, error) {
+	url := fmt.Sprintf("https://iss.moex.com/iss/securities/%v.json?iss.meta=off&iss.only=description&description.columns=name,value", id)
+
+	var response struct {
+		Description struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"description"`
+	}
+	if err := Get(url, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Description.Data) == 0 {
+		return nil, fmt.Errorf("security `%v' not found", id)
+	}
+
+	var d Description
+	for _, v := range response.Description.Data {
+		if len(v) != 2 {
+			return nil, fmt.Errorf("unknown format `%v'", v)
+		}
+
+		var value = String(v[1])
+		switch String(v[0]) {
+		case "SECID":
+			d.SecID = value
+		case "ISIN":
+			d.ISIN = value
+		case "NAME":
+			d.Name = value
+		case "SHORTNAME":
+			d.ShortName = value
+		case "TYPE":
+			d.Type = value
+		case "GROUP":
+			d.Group = value
+		case "FACEVALUE":
+			d.FaceValue, _ = strconv.ParseFloat(value, 64)
+		case "COUPONPERCENT":
+			d.CouponPercent, _ = strconv.ParseFloat(value, 64)
+		case "MATDATE":
+			d.MaturityDate, _ = ParseDate(value)
+		}
+	}
+
+	return &d, nil
+}
+
+// DownloadLotSize returns security lot size at the first board of `boards' where it's traded
+func DownloadLotSize(engine, market, secid string, boards map[string]bool) (float64, error) {
+	url := fmt.Sprintf("https://iss.moex.com/iss/engines/%v/markets/%v/securities/%v.json?iss.meta=off&iss.only=securities&securities.columns=BOARDID,LOTSIZE",
+		engine, market, secid)
+
+	var response struct {
+		Securities struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"securities"`
+	}
+	if err := Get(url, &response); err != nil {
+		return 0, err
+	}
+
+	for _, v := range response.Securities.Data {
+		if len(v) != 2 {
+			return 0, fmt.Errorf("unknown format `%v'", v)
+		}
+		if boards[String(v[0])] && Float(v[1]) > 0 {
+			return Float(v[1]), nil
+		}
+	}
+
+	return 0, fmt.Errorf("can't detect `%v' lot size", secid)
+}
@@ -0,0 +1,48 @@
+package moex
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Dividend is a dividend payment per share
+type Dividend struct {
+	Date     time.Time `json:"date"` // registry close date
+	Value    float64   `json:"value"`
+	Currency string    `json:"currency"`
+}
+
+// DownloadDividends returns share dividend history sorted by date
+func DownloadDividends(secid string) ([]Dividend, error) {
+	url := fmt.Sprintf("https://iss.moex.com/iss/securities/%v/dividends.json?iss.meta=off&dividends.columns=registryclosedate,value,currencyid", secid)
+
+	var response struct {
+		Dividends struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"dividends"`
+	}
+	if err := Get(url, &response); err != nil {
+		return nil, err
+	}
+
+	var result []Dividend
+	for _, v := range response.Dividends.Data {
+		if len(v) != 3 {
+			return nil, fmt.Errorf("unknown format `%v'", v)
+		}
+
+		date, err := ParseDate(String(v[0]))
+		if err != nil {
+			return nil, fmt.Errorf("bad dividend date `%v': %v", v[0], err)
+		}
+
+		result = append(result, Dividend{Date: date, Value: Float(v[1]), Currency: String(v[2])})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package moex
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Emitent describes security issuer
+type Emitent struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	INN   string `json:"inn"`
+}
+
+// DownloadEmitents returns emitents of all `market' (bonds, shares) securities by secid
+func DownloadEmitents(market string) (map[string]*Emitent, error) {
+	var result = make(map[string]*Emitent)
+
+	var offset = 0
+	for {
+		var columns = []string{"secid", "type", "emitent_title", "emitent_inn"}
+
+		list := strings.Join(columns, ",")
+		url := fmt.Sprintf("https://iss.moex.com/iss/securities.json?engine=stock&market=%v&iss.meta=off&securities.columns=%v&start=%v", market, list, offset)
+
+		var response struct {
+			Securities struct {
+				Data [][]string `json:"data"`
+			} `json:"securities"`
+		}
+		if err := Get(url, &response); err != nil {
+			return nil, err
+		}
+
+		for _, v := range response.Securities.Data {
+			if len(v) != len(columns) {
+				log.Fatalf("unknown format `%v'", v)
+			}
+
+			result[v[0]] = &Emitent{Type: v[1], Title: v[2], INN: v[3]}
+		}
+		if len(response.Securities.Data) == 0 {
+			break
+		}
+
+		offset += len(response.Securities.Data)
+	}
+
+	return result, nil
+}
+
+// DownloadEmitent returns emitent of the security (secid or isin)
+func DownloadEmitent(id string) (*Emitent, error) {
+	var columns = []string{"secid", "isin", "type", "emitent_title", "emitent_inn"}
+
+	url := fmt.Sprintf("https://iss.moex.com/iss/securities.json?q=%v&iss.meta=off&securities.columns=%v", id, strings.Join(columns, ","))
+
+	var response struct {
+		Securities struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"securities"`
+	}
+	if err := Get(url, &response); err != nil {
+		return nil, err
+	}
+
+	for _, v := range response.Securities.Data {
+		if len(v) != len(columns) {
+			return nil, fmt.Errorf("unknown format `%v'", v)
+		}
+
+		if String(v[0]) == id || String(v[1]) == id {
+			return &Emitent{Type: String(v[2]), Title: String(v[3]), INN: String(v[4])}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("security `%v' not found", id)
+}
@@ -0,0 +1,61 @@
+package moex
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Quote is a security close price of the trading day
+type Quote struct {
+	Date  time.Time `json:"date"`
+	Close float64   `json:"close"`
+}
+
+// Quotes contains quotes sorted by date
+type Quotes []Quote
+
+// At returns the last known close price at `date', false is returned if there are
+// no quotes till `date' (e.g. security isn't placed yet)
+func (q Quotes) At(date time.Time) (float64, bool) {
+	i := sort.Search(len(q), func(i int) bool { return q[i].Date.After(date) })
+	if i == 0 {
+		return 0, false
+	}
+
+	return q[i-1].Close, true
+}
+
+// DownloadQuotes returns close prices of the security in [from, till] interval
+// (the first found board of `boards' is used for each day)
+func DownloadQuotes(engine, market, secid string, boards map[string]bool, from, till time.Time) (Quotes, error) {
+	url := fmt.Sprintf("https://iss.moex.com/iss/history/engines/%v/markets/%v/securities/%v.json?iss.meta=off&from=%v&till=%v&history.columns=TRADEDATE,BOARDID,CLOSE",
+		engine, market, secid, from.Format(DateFormat), till.Format(DateFormat))
+
+	rows, err := GetHistory(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Quotes
+	for _, v := range rows {
+		if len(v) != 3 || !boards[String(v[1])] || Float(v[2]) <= 0 {
+			continue
+		}
+
+		date, err := ParseDate(String(v[0]))
+		if err != nil {
+			return nil, fmt.Errorf("bad date `%v': %v", v[0], err)
+		}
+		if len(result) != 0 && !result[len(result)-1].Date.Before(date) {
+			continue
+		}
+
+		result = append(result, Quote{Date: date, Close: Float(v[2])})
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("there is no `%v' history", secid)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package moex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotesAt(t *testing.T) {
+	var date = func(s string) time.Time {
+		d, err := ParseDate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return d
+	}
+
+	var quotes = Quotes{
+		{Date: date("2024-10-07"), Close: 100},
+		{Date: date("2024-10-08"), Close: 101},
+		{Date: date("2024-10-11"), Close: 99},
+	}
+
+	for _, tt := range []struct {
+		date  string
+		close float64
+		ok    bool
+	}{
+		{date: "2024-10-04"},
+		{date: "2024-10-07", close: 100, ok: true},
+		{date: "2024-10-10", close: 101, ok: true},
+		{date: "2024-10-11", close: 99, ok: true},
+		{date: "2024-10-14", close: 99, ok: true},
+	} {
+		close, ok := quotes.At(date(tt.date))
+		if close != tt.close || ok != tt.ok {
+			t.Errorf("At(%v) = %v, %v; expected %v, %v", tt.date, close, ok, tt.close, tt.ok)
+		}
+	}
+
+	if _, ok := Quotes(nil).At(date("2024-10-10")); ok {
+		t.Errorf("empty quotes are found")
+	}
+}
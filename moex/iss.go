@@ -0,0 +1,131 @@
+package moex
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheDir is a directory for iss responses snapshots (disabled when empty), snapshots are
+// stored per CacheDate, so runs as of the same date are reproducible, but live data
+// (e.g. prices and schedules without a date in url) is requested again at the next day
+var CacheDir string
+
+// CacheDate is a date of the requested data, e.g. `-as-of' date (by default: today)
+var CacheDate time.Time
+
+// Get requests iss `url' and decodes json response into `v'
+func Get(url string, v interface{}) error {
+	data, err := get(url)
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode `%v' failed: %v", string(data), err)
+	}
+
+	return nil
+}
+
+func get(url string) ([]byte, error) {
+	var snapshot string
+	if CacheDir != "" {
+		var date = CacheDate
+		if date.IsZero() {
+			date = Today()
+		}
+
+		snapshot = filepath.Join(CacheDir, date.Format(DateFormat), fmt.Sprintf("%x.json", sha1.Sum([]byte(url))))
+
+		data, err := ioutil.ReadFile(snapshot)
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("can't read snapshot `%v': %v", snapshot, err)
+		}
+	}
+
+	log.Printf("requesting `%v' ...", url)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("GET failed: %v", err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("body read failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET `%v' failed: %v", url, resp.Status)
+	}
+
+	if snapshot != "" {
+		if err = os.MkdirAll(filepath.Dir(snapshot), 0755); err != nil {
+			return nil, fmt.Errorf("can't create snapshots directory: %v", err)
+		}
+
+		var tmp = snapshot + ".tmp"
+		if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+			return nil, fmt.Errorf("can't store snapshot `%v': %v", tmp, err)
+		}
+		if err = os.Rename(tmp, snapshot); err != nil {
+			return nil, fmt.Errorf("can't rename `%v' -> `%v': %v", tmp, snapshot, err)
+		}
+	}
+
+	return data, nil
+}
+
+// GetHistory requests all pages of iss history `url' (it must contain query part)
+// and returns rows of `history' table
+func GetHistory(url string) ([][]interface{}, error) {
+	var result [][]interface{}
+
+	for start := 0; ; {
+		var response struct {
+			History struct {
+				Data [][]interface{} `json:"data"`
+			} `json:"history"`
+			Cursor struct {
+				Data [][]float64 `json:"data"` // INDEX, TOTAL, PAGESIZE
+			} `json:"history.cursor"`
+		}
+		if err := Get(fmt.Sprintf("%v&start=%v", url, start), &response); err != nil {
+			return nil, err
+		}
+		if len(response.History.Data) == 0 {
+			break
+		}
+
+		result = append(result, response.History.Data...)
+		start += len(response.History.Data)
+
+		if len(response.Cursor.Data) == 1 && len(response.Cursor.Data[0]) > 1 && float64(start) >= response.Cursor.Data[0][1] {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// Float converts iss json value into float (null is converted into zero)
+func Float(v interface{}) float64 {
+	f, _ := v.(float64)
+	return f
+}
+
+// String converts iss json value into string (null is converted into empty string)
+func String(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
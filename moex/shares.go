@@ -0,0 +1,74 @@
+package moex
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Share describes share traded at the exchange board
+type Share struct {
+	SecID     string  `json:"secid"`
+	ISIN      string  `json:"isin"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type"` // `1' - common, `2' - preferred
+	IssueSize float64 `json:"issue_size"`
+	LotSize   float64 `json:"lot_size"`
+	Price     float64 `json:"price"` // previous day close price
+
+	Capitalization float64 `json:"capitalization"`
+}
+
+// IsPreferred checks whether share is preferred
+func (s *Share) IsPreferred() bool {
+	return s.Type == "2"
+}
+
+// DownloadShares returns shares traded at `board' (e.g. TQBR) by secid
+func DownloadShares(board string) (map[string]*Share, error) {
+	var securitiesColumns = []string{"SECID", "ISIN", "SECNAME", "SECTYPE", "ISSUESIZE", "LOTSIZE", "PREVPRICE"}
+	var marketdataColumns = []string{"SECID", "ISSUECAPITALIZATION"}
+
+	url := fmt.Sprintf("https://iss.moex.com/iss/engines/stock/markets/shares/boards/%v/securities.json?iss.meta=off&iss.only=securities,marketdata&securities.columns=%v&marketdata.columns=%v",
+		board, strings.Join(securitiesColumns, ","), strings.Join(marketdataColumns, ","))
+
+	var response struct {
+		Securities struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"securities"`
+		Marketdata struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"marketdata"`
+	}
+	if err := Get(url, &response); err != nil {
+		return nil, err
+	}
+
+	var result = make(map[string]*Share)
+	for _, v := range response.Securities.Data {
+		if len(v) != len(securitiesColumns) {
+			log.Fatalf("unknown format `%v'", v)
+		}
+
+		result[String(v[0])] = &Share{
+			SecID:     String(v[0]),
+			ISIN:      String(v[1]),
+			Name:      String(v[2]),
+			Type:      String(v[3]),
+			IssueSize: Float(v[4]),
+			LotSize:   Float(v[5]),
+			Price:     Float(v[6]),
+		}
+	}
+	for _, v := range response.Marketdata.Data {
+		if len(v) != len(marketdataColumns) {
+			log.Fatalf("unknown format `%v'", v)
+		}
+
+		if s := result[String(v[0])]; s != nil {
+			s.Capitalization = Float(v[1])
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,37 @@
+// Package moex contains helpers to work with moscow exchange: its timezone,
+// trading calendar and iss api (https://iss.moex.com/iss/reference/)
+package moex
+
+import "time"
+
+// DateFormat is the date format used by iss
+const DateFormat = "2006-01-02"
+
+// Location is the exchange timezone, all dates are expected to be there
+var Location = loadLocation()
+
+func loadLocation() *time.Location {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		// tz database may be missing, moscow doesn't use dst since 2014
+		return time.FixedZone("MSK", 3*60*60)
+	}
+
+	return loc
+}
+
+// ParseDate parses `yyyy-mm-dd' date in moscow timezone
+func ParseDate(s string) (time.Time, error) {
+	return time.ParseInLocation(DateFormat, s, Location)
+}
+
+// Date returns the start of the `t' day in moscow timezone
+func Date(t time.Time) time.Time {
+	y, m, d := t.In(Location).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, Location)
+}
+
+// Today returns the start of the current day in moscow timezone
+func Today() time.Time {
+	return Date(time.Now())
+}
@@ -0,0 +1,365 @@
+package portfolio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// asset types
+const (
+	TypeBond         = "bond"
+	TypeStock        = "stock"
+	TypeFund         = "fund"
+	TypeETF          = "etf"
+	TypeDivFund      = "div_fund"
+	TypeCurrency     = "currency"
+	TypeCrowdLanding = "crowd_landing"
+)
+
+// Types contains all known asset types
+var Types = []string{TypeBond, TypeStock, TypeFund, TypeETF, TypeDivFund, TypeCurrency, TypeCrowdLanding}
+
+// Asset is a portfolio position of any type
+type Asset interface {
+	Info() *Base
+
+	// Value returns position value (price must be known)
+	Value() float64
+	// CashFlow returns expected yearly cash flow after `tax' (fraction)
+	CashFlow(tax float64) float64
+
+	validate() error
+}
+
+// Base contains fields common for all asset types
+type Base struct {
+	Type   string  `json:"type"`
+	ISIN   string  `json:"isin,omitempty"`
+	Ticker string  `json:"ticker,omitempty"`
+	Name   string  `json:"name,omitempty"`
+	Price  float64 `json:"price,omitempty"` // bond price is a percent of nominal
+
+	// price, nominal and dividends currency (rub by default), currency assets are priced in rubles
+	Currency string `json:"currency,omitempty"`
+
+	Emitent string `json:"emitent,omitempty"`
+	INN     string `json:"inn,omitempty"` // emitent inn, detected using iss if missing
+	Sector  string `json:"sector,omitempty"`
+
+	// sensitivity to the equity market used by stress test, 1 if missing
+	Beta float64 `json:"beta,omitempty"`
+
+	AvgPrice float64 `json:"avg_price,omitempty"` // purchase price, used to calculate sell tax
+}
+
+// Info returns common asset fields
+func (b *Base) Info() *Base {
+	return b
+}
+
+// ID returns asset identifier used for price lookup
+func (b *Base) ID() string {
+	if b.ISIN != "" {
+		return b.ISIN
+	}
+
+	return b.Ticker
+}
+
+func (b *Base) String() string {
+	if b.Name != "" {
+		return fmt.Sprintf("%v `%v'", b.Type, b.Name)
+	}
+	if id := b.ID(); id != "" {
+		return fmt.Sprintf("%v `%v'", b.Type, id)
+	}
+
+	return b.Type
+}
+
+// Bond is a fixed coupon bond
+type Bond struct {
+	Base
+
+	Count        float64 `json:"count"`
+	Nominal      float64 `json:"nominal"`
+	Percent      float64 `json:"percent"` // yearly coupon
+	MaturityDate string  `json:"maturity_date"`
+	Rating       string  `json:"rating,omitempty"` // credit rating, used by swap
+
+	maturity time.Time
+}
+
+// Value returns bonds value without accrued interest
+func (b *Bond) Value() float64 {
+	return b.Nominal * b.Price / 100.0 * b.Count
+}
+
+// CashFlow returns yearly coupons after tax
+func (b *Bond) CashFlow(tax float64) float64 {
+	return b.Nominal * b.Percent / 100.0 * b.Count * (1 - tax)
+}
+
+// IsExpired checks whether bond is matured before `today'
+func (b *Bond) IsExpired(today time.Time) bool {
+	return b.maturity.Before(today)
+}
+
+func (b *Bond) validate() error {
+	if b.ISIN == "" {
+		return fmt.Errorf("isin is required")
+	}
+	if b.Count <= 0 {
+		return fmt.Errorf("count must be positive")
+	}
+	if b.Nominal <= 0 {
+		return fmt.Errorf("nominal must be positive")
+	}
+	if b.Percent < 0 {
+		return fmt.Errorf("percent can't be negative")
+	}
+
+	var err error
+	if b.maturity, err = moex.ParseDate(b.MaturityDate); err != nil {
+		return fmt.Errorf("invalid maturity date `%v': %v", b.MaturityDate, err)
+	}
+
+	return nil
+}
+
+// Stock is a share traded by lots (the same format is used for etf)
+type Stock struct {
+	Base
+
+	LotCount      float64 `json:"lot_count"`
+	LotSize       float64 `json:"lot_size"`
+	DividendYield float64 `json:"dividend_yield,omitempty"` // yearly, percent, overrides dividends history
+
+	DividendPlan []*PlannedDividend `json:"dividend_plan,omitempty"` // used by forecast instead of history
+
+	historyYield float64 // calculated using dividends history
+	source       string
+}
+
+// PlannedDividend is an expected dividend per share
+type PlannedDividend struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+
+	date time.Time
+}
+
+// Count returns number of shares
+func (s *Stock) Count() float64 {
+	return s.LotCount * s.LotSize
+}
+
+// Value returns shares value
+func (s *Stock) Value() float64 {
+	return s.Price * s.Count()
+}
+
+// CashFlow returns yearly dividends after tax
+func (s *Stock) CashFlow(tax float64) float64 {
+	yield, _ := s.yield()
+	return yield / 100.0 * s.Value() * (1 - tax)
+}
+
+func (s *Stock) validate() error {
+	if s.Ticker == "" {
+		return fmt.Errorf("ticker is required")
+	}
+	if s.LotCount < 0 {
+		return fmt.Errorf("lot count can't be negative")
+	}
+	if s.LotSize <= 0 {
+		return fmt.Errorf("lot size must be positive")
+	}
+	if s.DividendYield < 0 {
+		return fmt.Errorf("dividend yield can't be negative")
+	}
+
+	for _, d := range s.DividendPlan {
+		var err error
+		if d.date, err = moex.ParseDate(d.Date); err != nil {
+			return fmt.Errorf("invalid dividend plan date `%v': %v", d.Date, err)
+		}
+		if d.Value <= 0 {
+			return fmt.Errorf("planned dividend must be positive")
+		}
+	}
+
+	return nil
+}
+
+// Fund is a mutual fund share, its dividends aren't taken into account
+// (use `div_fund' for them)
+type Fund struct {
+	Base
+
+	Count           float64 `json:"count"`
+	RawDividend     float64 `json:"raw_dividend,omitempty"` // per share, before tax
+	DividendPeriods float64 `json:"dividend_periods,omitempty"`
+
+	// net asset value per share history: csv file (`date,value') or its url published by management company
+	NAV string `json:"nav,omitempty"`
+}
+
+// Value returns fund shares value
+func (f *Fund) Value() float64 {
+	return f.Price * f.Count
+}
+
+// CashFlow returns zero, fund dividends are reinvested
+func (f *Fund) CashFlow(tax float64) float64 {
+	return 0
+}
+
+func (f *Fund) validate() error {
+	if f.ID() == "" && f.Price == 0 {
+		return fmt.Errorf("isin or price is required")
+	}
+	if f.Count <= 0 {
+		return fmt.Errorf("count must be positive")
+	}
+	if f.RawDividend < 0 || f.DividendPeriods < 0 {
+		return fmt.Errorf("dividend can't be negative")
+	}
+
+	return nil
+}
+
+// DivFund is a mutual fund share paying dividends
+type DivFund struct {
+	Fund
+}
+
+// CashFlow returns yearly dividends after tax
+func (f *DivFund) CashFlow(tax float64) float64 {
+	return f.RawDividend * f.Count * f.DividendPeriods * (1 - tax)
+}
+
+func (f *DivFund) validate() error {
+	if err := f.Fund.validate(); err != nil {
+		return err
+	}
+	if f.DividendPeriods == 0 {
+		return fmt.Errorf("dividend periods are required")
+	}
+
+	return nil
+}
+
+// Currency is a currency (or metal) position traded at moex
+type Currency struct {
+	Base
+
+	Count float64 `json:"count"`
+}
+
+// Value returns position value in rubles
+func (c *Currency) Value() float64 {
+	return c.Price * c.Count
+}
+
+// CashFlow returns zero, currency doesn't pay anything
+func (c *Currency) CashFlow(tax float64) float64 {
+	return 0
+}
+
+func (c *Currency) validate() error {
+	if c.Ticker == "" {
+		return fmt.Errorf("ticker is required")
+	}
+	if c.Count <= 0 {
+		return fmt.Errorf("count must be positive")
+	}
+
+	return nil
+}
+
+// CrowdLanding is a crowdlending platform account, price is the invested sum;
+// loan book model is used instead of flat dividend if loans or rate are specified
+// (loan book is loaded by Portfolio.LoadLoanBooks, price isn't required then)
+type CrowdLanding struct {
+	Base
+
+	Dividend        float64 `json:"dividend"` // per period, after tax
+	DividendPeriods float64 `json:"dividend_periods"`
+
+	Loans       string  `json:"loans,omitempty"`        // loan book csv export
+	Rate        float64 `json:"rate,omitempty"`         // loans rate percent, used without loan book
+	TermMonths  float64 `json:"term_months,omitempty"`  // average loan term, used without loan book
+	DefaultRate float64 `json:"default_rate,omitempty"` // expected yearly defaults, percent of performing principal
+	Recovery    float64 `json:"recovery,omitempty"`     // percent of defaulted principal recovered
+
+	book  []*Loan
+	today time.Time
+}
+
+// Value returns invested sum or expected value of the loan book
+func (c *CrowdLanding) Value() float64 {
+	if c.Loans != "" {
+		return c.Model(0).Value
+	}
+
+	return c.Price
+}
+
+// CashFlow returns yearly income, platform pays it with tax already withheld
+// (loan book model income is reduced by expected defaults and `tax')
+func (c *CrowdLanding) CashFlow(tax float64) float64 {
+	if c.modeled() {
+		return c.Model(tax).Income
+	}
+
+	return c.Dividend * c.DividendPeriods
+}
+
+// periods returns number of payments a year
+func (c *CrowdLanding) periods() float64 {
+	if c.modeled() {
+		return 12
+	}
+
+	return c.DividendPeriods
+}
+
+func (c *CrowdLanding) validate() error {
+	if c.Dividend < 0 || c.DividendPeriods < 0 {
+		return fmt.Errorf("dividend can't be negative")
+	}
+	if c.Rate < 0 || c.TermMonths < 0 {
+		return fmt.Errorf("rate and term can't be negative")
+	}
+	if c.DefaultRate < 0 || c.DefaultRate > 100 || c.Recovery < 0 || c.Recovery > 100 {
+		return fmt.Errorf("default rate and recovery must be in [0, 100]")
+	}
+
+	if c.Price < 0 || (c.Price == 0 && c.Loans == "") {
+		return fmt.Errorf("price must be positive")
+	}
+
+	return nil
+}
+
+func newAsset(typ string) Asset {
+	switch typ {
+	case TypeBond:
+		return &Bond{}
+	case TypeStock, TypeETF:
+		return &Stock{}
+	case TypeFund:
+		return &Fund{}
+	case TypeDivFund:
+		return &DivFund{}
+	case TypeCurrency:
+		return &Currency{}
+	case TypeCrowdLanding:
+		return &CrowdLanding{}
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spectrec/invest-tools/bond"
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// BondYield contains held bond yield and risk calculated using its payments schedule
+type BondYield struct {
+	Company string `json:"company"`
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+
+	Value           float64   `json:"value"`     // in report currency, including accrued interest
+	YTM             float64   `json:"ytm"`       // percent, effective annual
+	NetYield        float64   `json:"net_yield"` // percent, simple after tax (like listing yield to maturity)
+	Duration        float64   `json:"duration"`
+	ModDuration     float64   `json:"modified_duration"` // floating coupons bonds are sensitive till the coupon reset
+	Convexity       float64   `json:"convexity"`
+	MaturityDate    time.Time `json:"maturity_date"`
+	YearsToMaturity float64   `json:"years_to_maturity"`
+
+	Spread float64 `json:"spread"` // ytm difference with the portfolio bonds ytm, percent points
+
+	FixedCoupon bool `json:"fixed_coupon"`        // floating coupons are estimated by the last known one
+	Estimated   bool `json:"estimated,omitempty"` // schedule is unknown, semiannual coupons are expected
+
+	risk bond.Risk
+}
+
+// bondSchedule contains future payments per bond
+type bondSchedule struct {
+	payments  bond.Schedule
+	nominal   float64 // current (not amortized) nominal
+	coupons   float64 // future coupons sum
+	accrued   float64
+	maturity  time.Time
+	fixed     bool
+	estimated bool
+}
+
+// schedule returns bond payments after `today' using iss bondization,
+// semiannual coupons of `percent' are expected if it's unavailable
+func (b *Bond) schedule(today time.Time) (*bondSchedule, error) {
+	var s = &bondSchedule{nominal: b.Nominal, maturity: b.maturity, fixed: true}
+	var payments = &s.payments
+
+	bz, err := moex.DownloadBondization(b.ISIN)
+	if err != nil || len(bz.Coupons) == 0 {
+		s.estimated = true
+
+		var coupon = b.Nominal * b.Percent / 100.0 / 2
+		var date = b.maturity
+		for ; date.After(today); date = date.AddDate(0, -6, 0) {
+			payments.Coupons = append([]bond.CashFlow{{Date: date, Value: coupon}}, payments.Coupons...)
+		}
+		if len(payments.Coupons) == 0 {
+			return nil, fmt.Errorf("bond is matured")
+		}
+
+		payments.Amortizations = []bond.CashFlow{{Date: b.maturity, Value: b.Nominal}}
+		s.accrued = bond.ActAct.AccruedInterest(bond.Coupon{Start: date, End: payments.Coupons[0].Date, Value: coupon}, today)
+		s.coupons = coupon * float64(len(payments.Coupons))
+
+		return s, nil
+	}
+
+	s.fixed = bz.FixedCoupon
+
+	// floating (unknown) coupons are estimated by the last known one
+	var prev time.Time
+	var last float64
+	for _, c := range bz.Coupons {
+		var value = c.Value
+		if value == 0 {
+			value = last
+		}
+		last = value
+
+		if !c.Date.After(today) {
+			prev = c.Date
+			continue
+		}
+		if c.Value == 0 && payments.Reset.IsZero() {
+			payments.Reset = c.Date
+		}
+
+		if len(payments.Coupons) == 0 && !prev.IsZero() {
+			s.accrued = bond.ActAct.AccruedInterest(bond.Coupon{Start: prev, End: c.Date, Value: value}, today)
+		}
+
+		payments.Coupons = append(payments.Coupons, bond.CashFlow{Date: c.Date, Value: value})
+		s.coupons += value
+	}
+
+	var left float64
+	for _, a := range bz.Amortizations {
+		if !a.Date.After(today) {
+			continue
+		}
+
+		left += a.Value
+		payments.Amortizations = append(payments.Amortizations, bond.CashFlow{Date: a.Date, Value: a.Value})
+		s.maturity = a.Date
+	}
+	if left > 0 {
+		s.nominal = left
+	}
+	if len(payments.Coupons) == 0 && len(payments.Amortizations) == 0 {
+		return nil, fmt.Errorf("bond is matured")
+	}
+
+	return s, nil
+}
+
+// bondYield calculates bond yield and risk at its current price, `tax' (fraction) is used for net yield
+func (p *Portfolio) bondYield(company string, b *Bond, tax float64, today time.Time) (*BondYield, error) {
+	s, err := b.schedule(today)
+	if err != nil {
+		return nil, err
+	}
+
+	var price = s.nominal*b.Price/100.0 + s.accrued
+
+	// floating coupons bonds are sensitive to rates till the coupon reset
+	ytm, risk, err := s.payments.Risk(today, price)
+	if err != nil {
+		return nil, err
+	}
+
+	var y = &BondYield{
+		Company: company,
+		ID:      b.ISIN,
+		Name:    b.Name,
+
+		Value:           p.convert(b, price*b.Count),
+		YTM:             ytm * 100,
+		MaturityDate:    s.maturity,
+		YearsToMaturity: bond.Days(today, s.maturity) / 365.0,
+		FixedCoupon:     s.fixed,
+		Estimated:       s.estimated,
+
+		risk: risk,
+	}
+	if days := bond.Days(today, s.maturity); days > 0 {
+		var taxes = s.coupons * tax
+		if s.nominal > price {
+			taxes += (s.nominal - price) * tax
+		}
+
+		y.NetYield = ((s.nominal+s.coupons-taxes)/price - 1) * (365.0 / days) * 100.0
+	}
+	y.Duration, y.ModDuration, y.Convexity = y.risk.Macaulay, y.risk.Modified, y.risk.Convexity
+
+	return y, nil
+}
+
+// bondYields calculates yields of not expired bonds, `fail' is called for bonds with unknown yield
+func (p *Portfolio) bondYields(tax float64, today time.Time, fail func(b *Bond, err error)) map[Asset]*BondYield {
+	var result = make(map[Asset]*BondYield)
+	for _, part := range p.Parts {
+		for _, a := range part.Assets {
+			b, ok := a.(*Bond)
+			if !ok || b.IsExpired(today) {
+				continue
+			}
+
+			y, err := p.bondYield(part.Company, b, tax, today)
+			if err != nil {
+				fail(b, err)
+				continue
+			}
+
+			result[a] = y
+		}
+	}
+
+	return result
+}
+
+// BondsReport contains fixed income part summary, averages are weighted by value
+type BondsReport struct {
+	Currency        string  `json:"currency"`
+	Value           float64 `json:"value"` // including accrued interest
+	YTM             float64 `json:"ytm"`   // percent
+	NetYield        float64 `json:"net_yield"`
+	Duration        float64 `json:"duration"`
+	ModDuration     float64 `json:"modified_duration"`
+	YearsToMaturity float64 `json:"years_to_maturity"`
+
+	Bonds    []*BondYield `json:"bonds"` // sorted by ytm, the worst ones are swap candidates
+	Warnings []string     `json:"warnings,omitempty"`
+}
+
+// Bonds calculates market yield to maturity and duration of held bonds using their
+// payments schedules and current prices (prices must be filled), `tax' is a fraction used for net yield
+func (p *Portfolio) Bonds(tax float64, today time.Time) *BondsReport {
+	var r = &BondsReport{Currency: p.Currency()}
+
+	var yields = p.bondYields(tax, today, func(b *Bond, err error) {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("%v: yield is unknown: %v", b, err))
+	})
+	for _, a := range p.Assets() {
+		if y := yields[a]; y != nil {
+			r.Bonds = append(r.Bonds, y)
+		}
+	}
+
+	for _, y := range r.Bonds {
+		r.Value += y.Value
+		r.YTM += y.YTM * y.Value
+		r.NetYield += y.NetYield * y.Value
+		r.Duration += y.Duration * y.Value
+		r.ModDuration += y.ModDuration * y.Value
+		r.YearsToMaturity += y.YearsToMaturity * y.Value
+	}
+	if r.Value > 0 {
+		r.YTM /= r.Value
+		r.NetYield /= r.Value
+		r.Duration /= r.Value
+		r.ModDuration /= r.Value
+		r.YearsToMaturity /= r.Value
+	}
+
+	for _, y := range r.Bonds {
+		y.Spread = y.YTM - r.YTM
+	}
+	sort.SliceStable(r.Bonds, func(i, j int) bool {
+		return r.Bonds[i].YTM < r.Bonds[j].YTM
+	})
+
+	return r
+}
@@ -0,0 +1,286 @@
+package portfolio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// loan statuses
+const (
+	LoanPerforming = "performing"
+	LoanOverdue    = "overdue"
+	LoanDefaulted  = "defaulted"
+)
+
+// Loan is a crowdlending loan book entry
+type Loan struct {
+	ID        string    `json:"id,omitempty"`
+	Borrower  string    `json:"borrower,omitempty"`
+	Principal float64   `json:"principal"` // outstanding
+	Rate      float64   `json:"rate"`      // yearly, percent
+	Maturity  time.Time `json:"maturity"`  // zero if unknown
+	Status    string    `json:"status"`
+}
+
+// loan book columns, several names of the column are listed by priority
+var loanColumnAliases = map[string][]string{
+	"id":        {"id", "номер займа", "номер договора", "займ", "заем", "договор", "loan"},
+	"borrower":  {"заемщик", "компания", "borrower", "company"},
+	"principal": {"остаток основного долга", "остаток долга", "остаток", "основной долг", "principal", "outstanding"},
+	"rate":      {"ставка", "процентная ставка", "ставка годовых", "rate", "interest rate"},
+	"maturity":  {"дата погашения", "срок погашения", "погашение", "maturity date", "maturity"},
+	"status":    {"статус", "состояние", "status"},
+}
+
+// loan statuses keywords (word prefixes) by priority, negated ones are skipped
+var loanStatusKeywords = []struct {
+	status   string
+	keywords []string
+}{
+	{LoanDefaulted, []string{"дефолт", "default", "списан", "банкрот"}},
+	{LoanOverdue, []string{"просроч", "задерж", "overdue", "late"}},
+}
+
+var loanStatusNegations = map[string]bool{"без": true, "нет": true, "не": true, "no": true, "not": true}
+
+// loanStatus converts platform status into loan status, e.g. `просрочка 45 дней' is overdue,
+// but `без просрочки' is performing
+func loanStatus(s string) string {
+	var words = strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	for _, v := range loanStatusKeywords {
+		for i, w := range words {
+			if i > 0 && loanStatusNegations[words[i-1]] {
+				continue
+			}
+
+			for _, k := range v.keywords {
+				if strings.HasPrefix(w, k) {
+					return v.status
+				}
+			}
+		}
+	}
+
+	return LoanPerforming
+}
+
+// LoadLoanBook reads platform loan book csv export, columns are detected by header names
+// (principal is required, rows without it are skipped)
+func LoadLoanBook(path string) ([]*Loan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := readCSV(data)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode `%v': %v", path, err)
+	}
+
+	var columns map[string]int
+	var result []*Loan
+	for _, row := range rows {
+		if columns == nil {
+			columns = loanColumnIndexes(row)
+			continue
+		}
+
+		var cell = func(column string) string {
+			if i, ok := columns[column]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+
+			return ""
+		}
+
+		principal, err := parseNumber(strings.TrimSuffix(cell("principal"), "₽"))
+		if err != nil || principal <= 0 {
+			continue
+		}
+
+		var l = &Loan{ID: cell("id"), Borrower: cell("borrower"), Principal: principal, Status: loanStatus(cell("status"))}
+		if l.Rate, err = parseNumber(strings.TrimSuffix(cell("rate"), "%")); err != nil && cell("rate") != "" {
+			return nil, fmt.Errorf("`%v': bad loan `%v' rate `%v'", path, l.ID, cell("rate"))
+		}
+		if date := cell("maturity"); date != "" {
+			if l.Maturity, err = parseReportDate(date); err != nil {
+				return nil, fmt.Errorf("`%v': bad loan `%v' maturity date `%v'", path, l.ID, date)
+			}
+		}
+
+		result = append(result, l)
+	}
+	if columns == nil {
+		return nil, fmt.Errorf("`%v' doesn't contain principal column", path)
+	}
+
+	return result, nil
+}
+
+// LoadLoanBooks reads loan books of crowdlending accounts, relative paths
+// are resolved against `dir' (e.g. the portfolio file directory)
+func (p *Portfolio) LoadLoanBooks(dir string, today time.Time) error {
+	for _, a := range p.Assets() {
+		c, ok := a.(*CrowdLanding)
+		if !ok || c.Loans == "" {
+			continue
+		}
+
+		var path = c.Loans
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		book, err := LoadLoanBook(path)
+		if err != nil {
+			return fmt.Errorf("%v: can't load loan book: %v", c, err)
+		}
+
+		c.book, c.today = book, today
+	}
+
+	return nil
+}
+
+// loanColumnIndexes maps loan book columns to header cells, nil is returned if there is no principal
+func loanColumnIndexes(header []string) map[string]int {
+	var name2index = make(map[string]int)
+	for i, h := range header {
+		if _, ok := name2index[normalizeHeader(h)]; !ok {
+			name2index[normalizeHeader(h)] = i
+		}
+	}
+
+	var result = make(map[string]int)
+	for column, aliases := range loanColumnAliases {
+		for _, alias := range aliases {
+			if i, ok := name2index[alias]; ok {
+				result[column] = i
+				break
+			}
+		}
+	}
+
+	if _, ok := result["principal"]; !ok {
+		return nil
+	}
+
+	return result
+}
+
+// CrowdModel is a crowdlending account estimate taking defaults and tax into account,
+// overdue loans are expected to default
+type CrowdModel struct {
+	Loans      int     `json:"loans,omitempty"`
+	Performing float64 `json:"performing"` // principal of loans paid in time
+	Overdue    float64 `json:"overdue"`
+	Defaulted  float64 `json:"defaulted"`
+	Value      float64 `json:"value"` // performing principal and expected recovery of the bad loans
+
+	Rate           float64 `json:"rate"`            // performing loans rate, percent
+	Loss           float64 `json:"loss"`            // expected yearly defaults loss, percent of performing principal
+	NetYield       float64 `json:"net_yield"`       // after defaults and tax, percent of value
+	EffectiveYield float64 `json:"effective_yield"` // with monthly income reinvestment, percent
+	Income         float64 `json:"income"`          // yearly, after defaults and tax
+	Principal      float64 `json:"principal"`       // returned within a year, needs reinvesting
+}
+
+// modeled checks whether loan book model is used instead of flat dividend
+func (c *CrowdLanding) modeled() bool {
+	return c.Loans != "" || c.Rate > 0
+}
+
+// Model estimates account value and yield, `tax' (fraction) is applied to interest
+// reduced by defaults losses; loans are expected to amortize evenly till maturity
+func (c *CrowdLanding) Model(tax float64) *CrowdModel {
+	var m = &CrowdModel{}
+	var recovery = c.Recovery / 100
+
+	if c.Loans == "" {
+		m.Performing, m.Rate = c.Price, c.Rate
+		if c.TermMonths > 0 {
+			m.Principal = c.Price * math.Min(12/c.TermMonths, 1)
+		}
+	}
+
+	var interest float64
+	for _, l := range c.book {
+		m.Loans++
+
+		switch l.Status {
+		case LoanOverdue:
+			m.Overdue += l.Principal
+			continue
+		case LoanDefaulted:
+			m.Defaulted += l.Principal
+			continue
+		}
+
+		m.Performing += l.Principal
+		interest += l.Principal * l.Rate
+
+		if !l.Maturity.IsZero() {
+			var months = math.Max(l.Maturity.Sub(c.today).Hours()/24/30.4, 1)
+			m.Principal += l.Principal * math.Min(12/months, 1)
+		}
+	}
+	if c.Loans != "" && m.Performing > 0 {
+		m.Rate = interest / m.Performing
+	}
+
+	m.Value = m.Performing + (m.Overdue+m.Defaulted)*recovery
+	m.Loss = c.DefaultRate * (1 - recovery)
+
+	var income = m.Performing * (m.Rate - m.Loss) / 100
+	m.Income = income - math.Max(income, 0)*tax
+	if m.Value > 0 {
+		m.NetYield = m.Income / m.Value * 100
+		m.EffectiveYield = (math.Pow(1+m.NetYield/100/12, 12) - 1) * 100
+	}
+
+	return m
+}
+
+// CrowdAccount is a crowdlending account model
+type CrowdAccount struct {
+	Company string `json:"company"`
+	Name    string `json:"name,omitempty"`
+	Flat    bool   `json:"flat"` // flat dividend is used, there is no model
+
+	*CrowdModel
+}
+
+// Crowdlending estimates crowdlending accounts, flat dividend accounts yield is
+// calculated using their cash flow
+func (p *Portfolio) Crowdlending(tax float64) []*CrowdAccount {
+	var result []*CrowdAccount
+	for _, part := range p.Parts {
+		for _, a := range part.Assets {
+			c, ok := a.(*CrowdLanding)
+			if !ok {
+				continue
+			}
+
+			var acc = &CrowdAccount{Company: part.Company, Name: c.Name, Flat: !c.modeled()}
+			if acc.Flat {
+				acc.CrowdModel = &CrowdModel{Performing: c.Price, Value: c.Price, Income: c.CashFlow(tax)}
+				acc.NetYield = acc.Income / c.Price * 100
+				acc.EffectiveYield = (math.Pow(1+acc.NetYield/100/12, 12) - 1) * 100
+			} else {
+				acc.CrowdModel = c.Model(tax)
+			}
+
+			result = append(result, acc)
+		}
+	}
+
+	return result
+}
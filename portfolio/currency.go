@@ -0,0 +1,83 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RUB is the default currency of assets and reports
+const RUB = "RUB"
+
+// isRUB checks currency code, iss uses `SUR' for rubles
+func isRUB(currency string) bool {
+	return currency == "" || currency == RUB || currency == "SUR"
+}
+
+// Currencies returns non rub currencies of the assets
+func (p *Portfolio) Currencies() []string {
+	var uniq = make(map[string]bool)
+	for _, a := range p.Assets() {
+		if c := a.Info().Currency; !isRUB(c) {
+			uniq[c] = true
+		}
+	}
+
+	var result []string
+	for c := range uniq {
+		result = append(result, c)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// SetCurrency sets report currency, `rates' are rub prices of currency units
+// (metals are priced per gram), rates of all assets currencies are required
+func (p *Portfolio) SetCurrency(currency string, rates map[string]float64) error {
+	for _, c := range append(p.Currencies(), currency) {
+		if !isRUB(c) && rates[c] <= 0 {
+			return fmt.Errorf("`%v' rate is unknown", c)
+		}
+	}
+
+	p.currency, p.rates = currency, rates
+
+	return nil
+}
+
+// Currency returns report currency
+func (p *Portfolio) Currency() string {
+	if isRUB(p.currency) {
+		return RUB
+	}
+
+	return p.currency
+}
+
+func (p *Portfolio) rate(currency string) float64 {
+	if isRUB(currency) {
+		return 1
+	}
+
+	return p.rates[currency]
+}
+
+// toRUB converts asset currency value into rubles
+func (p *Portfolio) toRUB(a Asset, v float64) float64 {
+	return v * p.rate(a.Info().Currency)
+}
+
+// convert converts asset currency value into report currency
+func (p *Portfolio) convert(a Asset, v float64) float64 {
+	return p.toRUB(a, v) / p.rate(p.currency)
+}
+
+// value returns asset value in report currency
+func (p *Portfolio) value(a Asset) float64 {
+	return p.convert(a, a.Value())
+}
+
+// cashFlow returns asset cash flow in report currency
+func (p *Portfolio) cashFlow(a Asset, tax float64) float64 {
+	return p.convert(a, a.CashFlow(tax))
+}
@@ -0,0 +1,91 @@
+package portfolio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spectrec/invest-tools/dividend"
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// dividend yield sources
+const (
+	DividendManual  = "manual"  // `dividend_yield' field
+	DividendTTM     = "ttm"     // trailing 12 months
+	DividendAverage = "average" // average over the years
+)
+
+// DividendYield describes stock (or etf) dividend yield used for cash flow
+type DividendYield struct {
+	Company string  `json:"company"`
+	ID      string  `json:"id"`
+	Name    string  `json:"name,omitempty"`
+	Yield   float64 `json:"yield"` // percent, before tax
+	Source  string  `json:"source"`
+}
+
+// yield returns dividend yield percent and its source
+func (s *Stock) yield() (float64, string) {
+	if s.DividendYield > 0 {
+		return s.DividendYield, DividendManual
+	}
+
+	return s.historyYield, s.source
+}
+
+// FillDividends calculates dividend yield of stocks and etfs without `dividend_yield'
+// using iss dividends history, `source' is ttm or average (over `years' calendar years),
+// prices must be filled; it returns warnings about shares with unknown history
+func (p *Portfolio) FillDividends(source string, years int, today time.Time) ([]string, error) {
+	if source != DividendTTM && source != DividendAverage {
+		return nil, fmt.Errorf("unknown dividend source `%v'", source)
+	}
+
+	var warnings []string
+	for _, a := range p.Assets() {
+		s, ok := a.(*Stock)
+		if !ok || s.DividendYield > 0 {
+			continue
+		}
+
+		history, err := moex.DownloadDividends(s.Ticker)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%v: dividends history is unknown: %v", s, err))
+			continue
+		}
+
+		var currency = s.Currency
+		if isRUB(currency) {
+			currency = RUB
+		}
+
+		var stats = dividend.Analyze(history, currency, s.Price, today, years)
+		if s.historyYield, s.source = stats.TrailingYield, source; source == DividendAverage {
+			s.historyYield = stats.AverageYield
+		}
+	}
+
+	return warnings, nil
+}
+
+// dividendYields returns yields of stocks and etfs
+func (p *Portfolio) dividendYields() []*DividendYield {
+	var result []*DividendYield
+	for _, part := range p.Parts {
+		for _, a := range part.Assets {
+			s, ok := a.(*Stock)
+			if !ok {
+				continue
+			}
+
+			yield, source := s.yield()
+			if source == "" {
+				continue
+			}
+
+			result = append(result, &DividendYield{Company: part.Company, ID: s.ID(), Name: s.Name, Yield: yield, Source: source})
+		}
+	}
+
+	return result
+}
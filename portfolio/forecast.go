@@ -0,0 +1,257 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// forecast event kinds
+const (
+	EventCoupon     = "coupon"
+	EventDividend   = "dividend"
+	EventRedemption = "redemption" // amortization or maturity, money needs reinvesting
+	EventOffer      = "offer"      // put offer date, bond may be redeemed
+)
+
+// ForecastEvent is an expected payment
+type ForecastEvent struct {
+	Date      time.Time `json:"date"`
+	Company   string    `json:"company"`
+	ID        string    `json:"id,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Kind      string    `json:"kind"`
+	Amount    float64   `json:"amount"`              // in report currency, after tax
+	Estimated bool      `json:"estimated,omitempty"` // payment value isn't announced
+}
+
+// ForecastMonth contains payments of the month
+type ForecastMonth struct {
+	Month       string           `json:"month"` // yyyy-mm
+	Coupons     float64          `json:"coupons"`
+	Dividends   float64          `json:"dividends"`
+	Redemptions float64          `json:"redemptions"`
+	Income      float64          `json:"income"` // coupons and dividends
+	Low         bool             `json:"low"`    // income is below the threshold
+	Offers      int              `json:"offers"` // number of offers
+	Events      []*ForecastEvent `json:"events"`
+
+	start time.Time
+}
+
+// Forecast is a month by month cash flow forecast
+type Forecast struct {
+	Currency      string           `json:"currency"`
+	Months        []*ForecastMonth `json:"months"`
+	Income        float64          `json:"income"`
+	AverageIncome float64          `json:"average_income"`
+	Redemptions   []*ForecastEvent `json:"redemptions"` // redemptions and offers
+	Warnings      []string         `json:"warnings,omitempty"`
+}
+
+// ForecastOptions contains forecast parameters
+type ForecastOptions struct {
+	Months    int     // forecast horizon, the current month is the first one
+	Tax       float64 // income tax (fraction)
+	LowIncome float64 // month is low if its income is less than this fraction of average income
+}
+
+// Forecast builds cash flow forecast: bond payments are taken from iss bondization
+// (unknown coupons are estimated by the last known one), stock dividends from `dividend_plan'
+// or last year iss history shifted by one year, funds and crowdlending pay evenly `dividend_periods' times a year
+func (p *Portfolio) Forecast(opts ForecastOptions, today time.Time) (*Forecast, error) {
+	if opts.Months <= 0 {
+		return nil, fmt.Errorf("forecast months must be positive")
+	}
+
+	var f = Forecast{Currency: p.Currency()}
+
+	var first = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	for i := 0; i < opts.Months; i++ {
+		var start = first.AddDate(0, i, 0)
+		f.Months = append(f.Months, &ForecastMonth{Month: start.Format("2006-01"), start: start})
+	}
+	var end = first.AddDate(0, opts.Months, 0)
+
+	var add = func(e *ForecastEvent) {
+		if e.Date.Before(today) || !e.Date.Before(end) {
+			return
+		}
+
+		var m = f.Months[(e.Date.Year()-first.Year())*12+int(e.Date.Month())-int(first.Month())]
+		switch e.Kind {
+		case EventCoupon:
+			m.Coupons += e.Amount
+		case EventDividend:
+			m.Dividends += e.Amount
+		case EventRedemption:
+			m.Redemptions += e.Amount
+		case EventOffer:
+			m.Offers++
+		}
+		if e.Kind == EventRedemption || e.Kind == EventOffer {
+			f.Redemptions = append(f.Redemptions, e)
+		}
+
+		m.Events = append(m.Events, e)
+	}
+
+	for _, part := range p.Parts {
+		for _, a := range part.Assets {
+			var info = a.Info()
+			var event = func(date time.Time, kind string, amount float64, estimated bool) {
+				add(&ForecastEvent{Date: date, Company: part.Company, ID: info.ID(), Name: info.Name, Kind: kind, Amount: p.convert(a, amount), Estimated: estimated})
+			}
+
+			var err error
+			switch v := a.(type) {
+			case *Bond:
+				err = forecastBond(v, opts.Tax, event)
+			case *Stock:
+				err = forecastStock(v, opts.Tax, today, end, event)
+			case *DivFund:
+				forecastPeriodic(first, opts.Months, v.DividendPeriods, v.CashFlow(opts.Tax), EventDividend, event)
+			case *CrowdLanding:
+				forecastPeriodic(first, opts.Months, v.periods(), v.CashFlow(opts.Tax), EventCoupon, event)
+			}
+			if err != nil {
+				f.Warnings = append(f.Warnings, fmt.Sprintf("%v: %v", info, err))
+			}
+		}
+	}
+
+	for _, m := range f.Months {
+		m.Income = m.Coupons + m.Dividends
+		f.Income += m.Income
+
+		sort.SliceStable(m.Events, func(i, j int) bool {
+			return m.Events[i].Date.Before(m.Events[j].Date)
+		})
+	}
+	f.AverageIncome = f.Income / float64(len(f.Months))
+
+	for _, m := range f.Months {
+		m.Low = m.Income < f.AverageIncome*opts.LowIncome
+	}
+
+	sort.SliceStable(f.Redemptions, func(i, j int) bool {
+		return f.Redemptions[i].Date.Before(f.Redemptions[j].Date)
+	})
+
+	return &f, nil
+}
+
+type eventFunc func(date time.Time, kind string, amount float64, estimated bool)
+
+func forecastBond(b *Bond, tax float64, event eventFunc) error {
+	schedule, err := moex.DownloadBondization(b.ISIN)
+	if err != nil || len(schedule.Coupons) == 0 {
+		// the maturity is known anyway
+		event(b.maturity, EventRedemption, b.Nominal*b.Count, false)
+		if err == nil {
+			err = fmt.Errorf("bondization not found")
+		}
+
+		return fmt.Errorf("coupons aren't forecasted: %v", err)
+	}
+
+	var last float64
+	for _, c := range schedule.Coupons {
+		var value, estimated = c.Value, false
+		if value == 0 {
+			value, estimated = last, true
+		}
+
+		event(c.Date, EventCoupon, value*b.Count*(1-tax), estimated)
+		last = value
+	}
+
+	for _, a := range schedule.Amortizations {
+		event(a.Date, EventRedemption, a.Value*b.Count, false)
+	}
+
+	for _, o := range schedule.Offers {
+		event(o, EventOffer, b.Nominal*b.Count, false)
+	}
+
+	return nil
+}
+
+func forecastStock(s *Stock, tax float64, today, end time.Time, event eventFunc) error {
+	if len(s.DividendPlan) != 0 {
+		for _, d := range s.DividendPlan {
+			event(d.date, EventDividend, d.Value*s.Count()*(1-tax), false)
+		}
+
+		return nil
+	}
+
+	history, err := moex.DownloadDividends(s.Ticker)
+	if err != nil {
+		return fmt.Errorf("dividends aren't forecasted: %v", err)
+	}
+
+	// announced payments are used as is, the last year payments are expected to be repeated
+	// (registry close date is used as payment date) in periods without announced ones
+	var yearAgo = today.AddDate(-1, 0, 0)
+	var announced, last []moex.Dividend
+	for _, d := range history {
+		if d.Date.Before(yearAgo) || !d.Date.Before(end) {
+			continue
+		}
+		if d.Currency != s.Currency && !(isRUB(d.Currency) && isRUB(s.Currency)) {
+			return fmt.Errorf("dividends are paid in `%v', but share currency is `%v'", d.Currency, s.Currency)
+		}
+
+		if d.Date.After(today) {
+			announced = append(announced, d)
+			event(d.Date, EventDividend, d.Value*s.Count()*(1-tax), false)
+		} else {
+			last = append(last, d)
+		}
+	}
+
+	// an announced payment replaces the repeated one if it is closer than half of the payments interval
+	var window = 365 * 24 * time.Hour / 2
+	if len(last) > 1 {
+		window /= time.Duration(len(last))
+	}
+	var isAnnounced = func(date time.Time) bool {
+		for _, d := range announced {
+			if diff := d.Date.Sub(date); diff < window && diff > -window {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, d := range last {
+		for date := d.Date.AddDate(1, 0, 0); date.Before(end); date = date.AddDate(1, 0, 0) {
+			if !isAnnounced(date) {
+				event(date, EventDividend, d.Value*s.Count()*(1-tax), true)
+			}
+		}
+	}
+
+	return nil
+}
+
+// forecastPeriodic spreads yearly `amount' evenly: `periods' payments a year starting from the first month
+func forecastPeriodic(first time.Time, months int, periods, amount float64, kind string, event eventFunc) {
+	if periods <= 0 || amount == 0 {
+		return
+	}
+
+	var step = int(12 / periods)
+	if step < 1 {
+		step = 1
+	}
+
+	for i := 0; i < months; i += step {
+		var date = first.AddDate(0, i+1, -1) // the last day of the month
+		event(date, kind, amount/periods, true)
+	}
+}
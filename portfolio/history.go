@@ -0,0 +1,354 @@
+package portfolio
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// MonthlySeries contains month (yyyy-mm) values, e.g. index close values
+type MonthlySeries map[string]float64
+
+// LoadMonthlySeries reads `yyyy/mm value' lines (strategy/data format)
+func LoadMonthlySeries(path string) (MonthlySeries, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var result = make(MonthlySeries)
+	var scanner = bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var fields = strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("`%v' line %v: unknown format `%v'", path, line, scanner.Text())
+		}
+
+		month, err := time.Parse("2006/01", fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("`%v' line %v: invalid month `%v'", path, line, fields[0])
+		}
+
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("`%v' line %v: invalid value `%v'", path, line, fields[1])
+		}
+
+		result[month.Format("2006-01")] = value
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// HistoryPoint is a portfolio valuation at the date
+type HistoryPoint struct {
+	Date      time.Time `json:"date"`
+	Value     float64   `json:"value"`
+	Flows     float64   `json:"flows"`               // deposits minus withdrawals since the previous point
+	Index     float64   `json:"index"`               // flows adjusted value, starts from 100
+	Drawdown  float64   `json:"drawdown"`            // percent from the index maximum
+	Benchmark float64   `json:"benchmark,omitempty"` // benchmark index, starts from 100 (zero if unknown)
+}
+
+// History is a portfolio valuation time series
+type History struct {
+	Currency string          `json:"currency"`
+	Points   []*HistoryPoint `json:"points"`
+
+	Return          float64   `json:"return"` // percent
+	AnnualReturn    float64   `json:"annual_return"`
+	MaxDrawdown     float64   `json:"max_drawdown"` // percent
+	MaxDrawdownPeak time.Time `json:"max_drawdown_peak"`
+	MaxDrawdownDate time.Time `json:"max_drawdown_date"`
+
+	BenchmarkReturn      float64 `json:"benchmark_return,omitempty"`
+	BenchmarkMaxDrawdown float64 `json:"benchmark_max_drawdown,omitempty"`
+
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// HistoryOptions contains history parameters
+type HistoryOptions struct {
+	From, Till time.Time
+	Step       string        // day, week, month
+	Ledger     *Ledger       // holdings are reconstructed using the ledger, current ones are used if it's nil
+	Benchmark  MonthlySeries // optional
+}
+
+// history contains quotes used for valuation
+type history struct {
+	p        *Portfolio
+	quotes   map[string]moex.Quotes // by asset id
+	rates    map[string]moex.Quotes // by currency
+	untraded map[string]bool        // assets without quotes at some dates (warned)
+	warnings []string
+}
+
+func (h *history) warn(format string, args ...interface{}) {
+	h.warnings = append(h.warnings, fmt.Sprintf(format, args...))
+}
+
+func (h *history) download(a Asset, from, till time.Time) {
+	var info = a.Info()
+	if _, ok := h.quotes[info.ID()]; ok {
+		return
+	}
+
+	if _, ok := units(a); ok && info.ID() != "" {
+		engine, market, id := issMarket(info)
+
+		quotes, err := moex.DownloadQuotes(engine, market, id, goodBoards, from, till)
+		if err != nil {
+			h.warn("%v: current price is used: %v", info, err)
+		}
+
+		h.quotes[info.ID()] = quotes
+	}
+
+	if c := info.Currency; !isRUB(c) {
+		h.downloadRate(c, from, till)
+	}
+}
+
+func (h *history) downloadRate(currency string, from, till time.Time) {
+	if _, ok := h.rates[currency]; ok || isRUB(currency) {
+		return
+	}
+
+	quotes, err := moex.DownloadQuotes("currency", "selt", moex.CurrencyTickers[currency], map[string]bool{"CETS": true}, from, till)
+	if err != nil {
+		h.warn("`%v' current rate is used: %v", currency, err)
+	}
+
+	h.rates[currency] = quotes
+}
+
+func (h *history) rate(currency string, date time.Time) float64 {
+	if rate, ok := h.rates[currency].At(date); ok {
+		return rate
+	}
+
+	return h.p.rate(currency)
+}
+
+// value returns `n' securities (or whole asset if it isn't exchange traded) value in report currency,
+// false is returned if the security has quotes, but it isn't traded till `date' (`fallback' is warned)
+func (h *history) value(a Asset, n float64, date time.Time, fallback string) (float64, bool) {
+	var info = a.Info()
+
+	var price = info.Price
+	if quotes := h.quotes[info.ID()]; len(quotes) != 0 {
+		close, ok := quotes.At(date)
+		if !ok {
+			if !h.untraded[info.ID()] {
+				h.untraded[info.ID()] = true
+				h.warn("%v: there are no quotes before %v, %v", info, quotes[0].Date.Format(moex.DateFormat), fallback)
+			}
+
+			return 0, false
+		}
+
+		price = close
+	}
+
+	var v float64
+	switch asset := a.(type) {
+	case *Bond:
+		v = asset.Nominal * price / 100.0 * n
+	case *CrowdLanding:
+		v = asset.Value()
+	default:
+		v = price * n
+	}
+
+	return v * h.rate(info.Currency, date) / h.rate(h.p.currency, date), true
+}
+
+// asset returns portfolio asset matching ledger holding
+func (p *Portfolio) asset(company, id string) Asset {
+	var result Asset
+	for _, part := range p.Parts {
+		for _, a := range part.Assets {
+			var info = a.Info()
+			if info.ISIN != id && info.Ticker != id {
+				continue
+			}
+			if part.Company == company {
+				return a
+			}
+
+			result = a
+		}
+	}
+
+	return result
+}
+
+func historyDates(from, till time.Time, step string) ([]time.Time, error) {
+	var next func(time.Time) time.Time
+	switch step {
+	case "day":
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case "week":
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	case "month":
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	default:
+		return nil, fmt.Errorf("unknown history step `%v'", step)
+	}
+	if !from.Before(till) {
+		return nil, fmt.Errorf("history start must be before its end")
+	}
+
+	var result []time.Time
+	for date := from; date.Before(till); date = next(date) {
+		result = append(result, date)
+	}
+
+	return append(result, till), nil
+}
+
+// History values portfolio at past dates using iss close prices and currency rates,
+// prices must be filled (they are used if history is unavailable); coupons and dividends
+// are taken into account only if holdings are reconstructed from the ledger (as cash)
+func (p *Portfolio) History(opts HistoryOptions) (*History, error) {
+	dates, err := historyDates(opts.From, opts.Till, opts.Step)
+	if err != nil {
+		return nil, err
+	}
+
+	var h = &history{p: p, quotes: make(map[string]moex.Quotes), rates: make(map[string]moex.Quotes), untraded: make(map[string]bool)}
+
+	// quotes before the start are required for non trading days
+	var from = opts.From.AddDate(0, 0, -14)
+	h.downloadRate(p.currency, from, opts.Till)
+
+	var ledgerHoldings = make(map[holdingKey]Asset)
+	if opts.Ledger == nil {
+		for _, a := range p.Assets() {
+			h.download(a, from, opts.Till)
+		}
+	} else {
+		for _, t := range opts.Ledger.Transactions {
+			var key = holdingKey{t.Company, t.ID}
+			if t.Operation != OpBuy || ledgerHoldings[key] != nil {
+				continue
+			}
+
+			if a := p.asset(t.Company, t.ID); a != nil {
+				ledgerHoldings[key] = a
+				h.download(a, from, opts.Till)
+			} else {
+				h.warn("`%v' isn't found in portfolio, the last deal price is used", t.ID)
+			}
+		}
+	}
+
+	var result = &History{Currency: p.Currency()}
+
+	var prev *HistoryPoint
+	var peak, peakIndex = opts.From, 0.0
+	// benchmark starts from its value at `from', missing months aren't compared
+	var benchmarkBase, benchmarkPeak = opts.Benchmark[opts.From.Format("2006-01")], 0.0
+	var benchmarkMissing []time.Time
+	if len(opts.Benchmark) != 0 && benchmarkBase <= 0 {
+		h.warn("benchmark value of %v is unknown, it isn't compared", opts.From.Format("2006-01"))
+	}
+	var next int // the first ledger transaction after the previous point
+	for _, date := range dates {
+		var point = &HistoryPoint{Date: date}
+
+		if opts.Ledger == nil {
+			for _, a := range p.Assets() {
+				n, _ := units(a)
+				if v, ok := h.value(a, n, date, "it's treated as absent"); ok {
+					point.Value += v
+				}
+			}
+		} else {
+			holdings, cash := opts.Ledger.Holdings(date)
+			for _, c := range cash {
+				point.Value += c / h.rate(p.currency, date)
+			}
+			for _, holding := range holdings {
+				var a = ledgerHoldings[holdingKey{holding.Company, holding.ID}]
+				if a != nil {
+					if v, ok := h.value(a, holding.Quantity, date, "the last deal price is used"); ok {
+						point.Value += v
+						continue
+					}
+				}
+
+				point.Value += holding.Quantity * holding.LastPrice / h.rate(p.currency, date)
+			}
+
+			for ; next < len(opts.Ledger.Transactions) && !opts.Ledger.Transactions[next].Date.After(date); next++ {
+				var t = opts.Ledger.Transactions[next]
+				switch t.Operation {
+				case OpDeposit:
+					point.Flows += t.Amount / h.rate(p.currency, date)
+				case OpWithdrawal:
+					point.Flows -= t.Amount / h.rate(p.currency, date)
+				}
+			}
+		}
+
+		point.Index = 100
+		if prev != nil && prev.Value > 0 {
+			point.Index = prev.Index * (point.Value - point.Flows) / prev.Value
+		} else if prev != nil {
+			point.Index = prev.Index
+		}
+
+		if point.Index > peakIndex {
+			peak, peakIndex = date, point.Index
+		}
+		point.Drawdown = (peakIndex - point.Index) / peakIndex * 100
+		if point.Drawdown > result.MaxDrawdown {
+			result.MaxDrawdown = point.Drawdown
+			result.MaxDrawdownPeak, result.MaxDrawdownDate = peak, date
+		}
+
+		if v := opts.Benchmark[date.Format("2006-01")]; benchmarkBase > 0 && v <= 0 {
+			benchmarkMissing = append(benchmarkMissing, date)
+		} else if benchmarkBase > 0 {
+			point.Benchmark = v / benchmarkBase * 100
+			if point.Benchmark > benchmarkPeak {
+				benchmarkPeak = point.Benchmark
+			}
+			if dd := (benchmarkPeak - point.Benchmark) / benchmarkPeak * 100; dd > result.BenchmarkMaxDrawdown {
+				result.BenchmarkMaxDrawdown = dd
+			}
+
+			result.BenchmarkReturn = point.Benchmark - 100
+		}
+
+		result.Points = append(result.Points, point)
+		prev = point
+	}
+
+	result.Return = prev.Index - 100
+	if days := opts.Till.Sub(opts.From).Hours() / 24; days > 0 && prev.Index > 0 {
+		result.AnnualReturn = (math.Pow(prev.Index/100, 365/days) - 1) * 100
+	}
+	if n := len(benchmarkMissing); n != 0 {
+		h.warn("benchmark series doesn't cover %v dates (%v .. %v)",
+			n, benchmarkMissing[0].Format(moex.DateFormat), benchmarkMissing[n-1].Format(moex.DateFormat))
+	}
+	result.Warnings = h.warnings
+
+	return result, nil
+}
@@ -0,0 +1,369 @@
+package portfolio
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Position is a security position found in the broker report
+type Position struct {
+	ISIN     string  `json:"isin,omitempty"`
+	Ticker   string  `json:"ticker,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Type     string  `json:"type,omitempty"` // asset type, empty if report doesn't contain it
+	Quantity float64 `json:"quantity"`       // number of securities (not lots)
+	Nominal  float64 `json:"nominal,omitempty"`
+	Price    float64 `json:"price,omitempty"`
+}
+
+// ID returns position identifier
+func (p *Position) ID() string {
+	if p.ISIN != "" {
+		return p.ISIN
+	}
+
+	return p.Ticker
+}
+
+// report columns, several names of the column are listed by priority
+// (finam, tinkoff and other brokers reports use different ones)
+var columnAliases = map[string][]string{
+	"isin":     {"isin", "isin код", "код isin", "isin ценной бумаги", "isin код ценной бумаги"},
+	"ticker":   {"тикер", "код актива", "код инструмента", "код цб", "торговый код", "ticker", "symbol", "asset code"},
+	"name":     {"сокращенное наименование актива", "наименование актива", "наименование цб", "наименование", "инструмент", "ценная бумага", "security name", "name", "security"},
+	"quantity": {"плановый исходящий остаток", "исходящий остаток", "остаток на конец периода", "количество на конец периода", "количество шт", "количество", "кол во", "quantity", "qty", "position", "balance"},
+	"nominal":  {"номинал", "nominal", "face value", "facevalue"},
+	"price":    {"цена закрытия", "рыночная цена", "цена", "close price", "price"},
+	"type":     {"тип актива", "вид актива", "вид цб", "тип цб", "тип", "asset type", "type"},
+}
+
+func normalizeHeader(s string) string {
+	s = strings.ToLower(strings.Replace(s, "ё", "е", -1))
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+
+		return ' '
+	}, s)
+
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// columnIndexes maps report columns to header cells, nil is returned
+// if header doesn't contain security identifier or quantity
+func columnIndexes(header []string) map[string]int {
+	var name2index = make(map[string]int)
+	for i, h := range header {
+		if _, ok := name2index[normalizeHeader(h)]; !ok {
+			name2index[normalizeHeader(h)] = i
+		}
+	}
+
+	var result = make(map[string]int)
+	for column, aliases := range columnAliases {
+		for _, alias := range aliases {
+			if i, ok := name2index[alias]; ok {
+				result[column] = i
+				break
+			}
+		}
+	}
+
+	_, hasISIN := result["isin"]
+	_, hasTicker := result["ticker"]
+	_, hasQuantity := result["quantity"]
+	if !(hasISIN || hasTicker) || !hasQuantity {
+		return nil
+	}
+
+	return result
+}
+
+// parseNumber parses `1 234,56' like numbers, if both `,' and `.' are used
+// (`1,234.56' or `1.234,56') the last one is a decimal separator
+func parseNumber(s string) (float64, error) {
+	var decimal = ','
+	if strings.LastIndex(s, ".") > strings.LastIndex(s, ",") {
+		decimal = '.'
+	}
+
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == ' ' || r == '\'' {
+			return -1
+		}
+		if r == ',' || r == '.' {
+			if r != decimal {
+				return -1
+			}
+
+			return '.'
+		}
+
+		return r
+	}, s)
+
+	return strconv.ParseFloat(s, 64)
+}
+
+// assetType converts report security type into asset type
+func assetType(s string) string {
+	s = strings.ToLower(s)
+
+	switch {
+	case strings.Contains(s, "облигац") || strings.Contains(s, "bond"):
+		return TypeBond
+	case strings.Contains(s, "бпиф") || strings.Contains(s, "etf"):
+		return TypeETF
+	case strings.Contains(s, "пиф") || strings.Contains(s, "пай") || strings.Contains(s, "fund"):
+		return TypeFund
+	case strings.Contains(s, "акци") || strings.Contains(s, "депозитарн") || strings.Contains(s, "share") || strings.Contains(s, "stock"):
+		return TypeStock
+	}
+
+	return ""
+}
+
+// positionsFromRows finds the first table with positions, its rows are parsed till
+// the first row without security identifier; positions of the same security are summed
+func positionsFromRows(rows [][]string) []*Position {
+	var columns map[string]int
+	var start int
+	for i, row := range rows {
+		if columns = columnIndexes(row); columns != nil {
+			start = i + 1
+			break
+		}
+	}
+	if columns == nil {
+		return nil
+	}
+
+	var cell = func(row []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+
+		return strings.TrimSpace(row[i])
+	}
+
+	var result []*Position
+	var id2position = make(map[string]*Position)
+	for _, row := range rows[start:] {
+		var p = &Position{
+			ISIN:   cell(row, "isin"),
+			Ticker: cell(row, "ticker"),
+			Name:   cell(row, "name"),
+			Type:   assetType(cell(row, "type")),
+		}
+		if p.ID() == "" {
+			break
+		}
+
+		var err error
+		if p.Quantity, err = parseNumber(cell(row, "quantity")); err != nil {
+			// totals and subheaders inside the table
+			continue
+		}
+		p.Nominal, _ = parseNumber(cell(row, "nominal"))
+		p.Price, _ = parseNumber(cell(row, "price"))
+
+		if prev := id2position[p.ID()]; prev != nil {
+			prev.Quantity += p.Quantity
+			continue
+		}
+
+		id2position[p.ID()] = p
+		result = append(result, p)
+	}
+
+	return result
+}
+
+// ImportReport parses broker report (csv, xlsx or xml) positions
+func ImportReport(path string) ([]*Position, error) {
+	var positions []*Position
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".txt":
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := readCSV(data)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse `%v': %v", path, err)
+		}
+
+		positions = positionsFromRows(rows)
+	case ".xlsx":
+		sheets, err := readXLSX(path)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse `%v': %v", path, err)
+		}
+
+		for _, rows := range sheets {
+			if positions = positionsFromRows(rows); positions != nil {
+				break
+			}
+		}
+	case ".xml":
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if positions, err = positionsFromXML(data); err != nil {
+			return nil, fmt.Errorf("can't parse `%v': %v", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown report format `%v' (csv, xlsx and xml are supported)", path)
+	}
+
+	if len(positions) == 0 {
+		return nil, fmt.Errorf("positions not found in `%v'", path)
+	}
+
+	return positions, nil
+}
+
+// readCSV parses csv in utf-8 or cp1251, delimiter is detected by the first lines
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		data = decodeCP1251(data)
+	}
+
+	var lines = bytes.SplitN(data, []byte("\n"), 20)
+	var delimiter, best = ';', 0
+	for _, d := range []rune{';', ',', '\t'} {
+		var count int
+		for _, line := range lines {
+			count += bytes.Count(line, []byte(string(d)))
+		}
+		if count > best {
+			delimiter, best = d, count
+		}
+	}
+
+	var r = csv.NewReader(bytes.NewReader(data))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	return r.ReadAll()
+}
+
+// decodeCP1251 converts windows-1251 text into utf-8 (only cyrillic letters are converted,
+// other non ascii symbols are replaced by `?')
+func decodeCP1251(data []byte) []byte {
+	var b bytes.Buffer
+	for _, c := range data {
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+		case c >= 0xc0:
+			b.WriteRune(rune(c-0xc0) + 'А')
+		case c == 0xa8:
+			b.WriteRune('Ё')
+		case c == 0xb8:
+			b.WriteRune('ё')
+		case c == 0xa0:
+			b.WriteRune(' ')
+		case c == 0xb9:
+			b.WriteRune('№')
+		default:
+			b.WriteByte('?')
+		}
+	}
+
+	return b.Bytes()
+}
+
+// positionsFromXML treats every element as a row: its attributes and
+// simple child elements are cells, the header is made of their names
+func positionsFromXML(data []byte) ([]*Position, error) {
+	type element struct {
+		names, values []string
+	}
+
+	var result []*Position
+	var id2position = make(map[string]*Position)
+	var add = func(e *element) {
+		for _, p := range positionsFromRows([][]string{e.names, e.values}) {
+			if prev := id2position[p.ID()]; prev != nil {
+				prev.Quantity += p.Quantity
+				continue
+			}
+
+			id2position[p.ID()] = p
+			result = append(result, p)
+		}
+	}
+
+	var stack []*element
+	var text bytes.Buffer
+	var dec = xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if !strings.EqualFold(charset, "windows-1251") {
+			return nil, fmt.Errorf("unsupported charset `%v'", charset)
+		}
+
+		data, err := ioutil.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+
+		return bytes.NewReader(decodeCP1251(data)), nil
+	}
+
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			var e = &element{}
+			for _, a := range t.Attr {
+				e.names = append(e.names, a.Name.Local)
+				e.values = append(e.values, a.Value)
+			}
+
+			stack = append(stack, e)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			var e = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if len(e.names) != 0 {
+				add(e)
+			} else if len(stack) != 0 {
+				var parent = stack[len(stack)-1]
+				parent.names = append(parent.names, t.Name.Local)
+				parent.values = append(parent.values, strings.TrimSpace(text.String()))
+			}
+
+			text.Reset()
+		}
+	}
+
+	return result, nil
+}
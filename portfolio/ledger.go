@@ -0,0 +1,248 @@
+package portfolio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// ledger operations
+const (
+	OpBuy        = "buy"
+	OpSell       = "sell"
+	OpCoupon     = "coupon"
+	OpDividend   = "dividend"
+	OpDeposit    = "deposit"
+	OpWithdrawal = "withdrawal"
+	OpFee        = "fee"
+)
+
+// Transaction is a single ledger record, amounts are in rubles:
+// buy and sell amount is the whole deal sum (including accrued interest), fee is paid separately
+type Transaction struct {
+	Date      time.Time `json:"date"`
+	Company   string    `json:"company"`
+	Operation string    `json:"operation"`
+	ID        string    `json:"id,omitempty"` // isin or ticker
+	Quantity  float64   `json:"quantity,omitempty"`
+	Price     float64   `json:"price,omitempty"`
+	Amount    float64   `json:"amount"`
+	Fee       float64   `json:"fee,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+}
+
+// Ledger contains transactions sorted by date
+type Ledger struct {
+	Transactions []*Transaction
+}
+
+// LoadLedger reads csv ledger, its columns are:
+//
+//	date,company,operation,id,quantity,price,amount,fee,comment
+//
+// `#' lines are comments, empty amount of buy and sell is quantity * price
+func LoadLedger(path string) (*Ledger, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r = csv.NewReader(file)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("can't parse `%v': %v", path, err)
+	}
+
+	var l Ledger
+	for i, rec := range records {
+		if i == 0 && len(rec) > 0 && rec[0] == "date" {
+			continue
+		}
+
+		t, err := parseTransaction(rec)
+		if err != nil {
+			return nil, fmt.Errorf("`%v' record #%v (%v): %v", path, i+1, strings.Join(rec, ","), err)
+		}
+
+		l.Transactions = append(l.Transactions, t)
+	}
+
+	sort.SliceStable(l.Transactions, func(i, j int) bool {
+		return l.Transactions[i].Date.Before(l.Transactions[j].Date)
+	})
+
+	if err = l.validate(); err != nil {
+		return nil, fmt.Errorf("invalid `%v': %v", path, err)
+	}
+
+	return &l, nil
+}
+
+func parseTransaction(rec []string) (*Transaction, error) {
+	for len(rec) < 9 {
+		rec = append(rec, "")
+	}
+
+	var t = &Transaction{
+		Company:   strings.TrimSpace(rec[1]),
+		Operation: strings.TrimSpace(rec[2]),
+		ID:        strings.TrimSpace(rec[3]),
+		Comment:   strings.TrimSpace(rec[8]),
+	}
+
+	var err error
+	if t.Date, err = moex.ParseDate(strings.TrimSpace(rec[0])); err != nil {
+		return nil, fmt.Errorf("invalid date: %v", err)
+	}
+
+	var numbers = []*float64{&t.Quantity, &t.Price, &t.Amount, &t.Fee}
+	for i, v := range numbers {
+		var s = strings.TrimSpace(rec[4+i])
+		if s == "" {
+			continue
+		}
+
+		if *v, err = parseNumber(s); err != nil {
+			return nil, fmt.Errorf("invalid number `%v'", s)
+		}
+		if *v < 0 {
+			return nil, fmt.Errorf("negative number `%v'", s)
+		}
+	}
+
+	if t.Company == "" {
+		return nil, fmt.Errorf("company is required")
+	}
+
+	switch t.Operation {
+	case OpBuy, OpSell:
+		if t.ID == "" || t.Quantity == 0 {
+			return nil, fmt.Errorf("id and quantity are required")
+		}
+		if t.Amount == 0 {
+			t.Amount = t.Quantity * t.Price
+		}
+		if t.Amount == 0 {
+			return nil, fmt.Errorf("amount or price is required")
+		}
+	case OpCoupon, OpDividend:
+		if t.ID == "" || t.Amount == 0 {
+			return nil, fmt.Errorf("id and amount are required")
+		}
+	case OpDeposit, OpWithdrawal, OpFee:
+		if t.Amount == 0 {
+			return nil, fmt.Errorf("amount is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown operation `%v'", t.Operation)
+	}
+
+	return t, nil
+}
+
+// validate checks that nothing is sold before purchase
+func (l *Ledger) validate() error {
+	var held = make(map[holdingKey]float64)
+	for _, t := range l.Transactions {
+		var key = holdingKey{t.Company, t.ID}
+
+		switch t.Operation {
+		case OpBuy:
+			held[key] += t.Quantity
+		case OpSell:
+			if held[key] < t.Quantity {
+				return fmt.Errorf("%v: `%v' sells %v of `%v', but only %v are held",
+					t.Date.Format(moex.DateFormat), t.Company, t.Quantity, t.ID, held[key])
+			}
+
+			held[key] -= t.Quantity
+		}
+	}
+
+	return nil
+}
+
+type holdingKey struct {
+	Company, ID string
+}
+
+// Holding is a ledger position
+type Holding struct {
+	Company  string  `json:"company"`
+	ID       string  `json:"id"`
+	Quantity float64 `json:"quantity"`
+
+	LastPrice float64   `json:"last_price"` // the last deal unit price (amount / quantity)
+	LastDate  time.Time `json:"last_date"`
+}
+
+// ledgerState is a ledger replay state
+type ledgerState struct {
+	cash     map[string]float64 // by company
+	holdings map[holdingKey]*Holding
+	order    []holdingKey
+}
+
+func newLedgerState() *ledgerState {
+	return &ledgerState{cash: make(map[string]float64), holdings: make(map[holdingKey]*Holding)}
+}
+
+func (s *ledgerState) apply(t *Transaction) {
+	var key = holdingKey{t.Company, t.ID}
+
+	switch t.Operation {
+	case OpBuy, OpSell:
+		h := s.holdings[key]
+		if h == nil {
+			h = &Holding{Company: t.Company, ID: t.ID}
+			s.holdings[key] = h
+			s.order = append(s.order, key)
+		}
+
+		h.LastPrice, h.LastDate = t.Amount/t.Quantity, t.Date
+		if t.Operation == OpBuy {
+			h.Quantity += t.Quantity
+			s.cash[t.Company] -= t.Amount
+		} else {
+			h.Quantity -= t.Quantity
+			s.cash[t.Company] += t.Amount
+		}
+	case OpCoupon, OpDividend, OpDeposit:
+		s.cash[t.Company] += t.Amount
+	case OpWithdrawal, OpFee:
+		s.cash[t.Company] -= t.Amount
+	}
+
+	s.cash[t.Company] -= t.Fee
+}
+
+// Holdings returns positions and cash (by company) after all transactions till `date' (inclusive)
+func (l *Ledger) Holdings(date time.Time) ([]*Holding, map[string]float64) {
+	var s = newLedgerState()
+	for _, t := range l.Transactions {
+		if t.Date.After(date) {
+			break
+		}
+
+		s.apply(t)
+	}
+
+	var result []*Holding
+	for _, key := range s.order {
+		if h := s.holdings[key]; h.Quantity > 0 {
+			result = append(result, h)
+		}
+	}
+
+	return result, s.cash
+}
@@ -0,0 +1,199 @@
+package portfolio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// NAVPoint is a fund net asset value per share at the date
+type NAVPoint struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+// reportDateFormats contains date formats used by management companies and platforms reports,
+// dates are parsed in moscow timezone
+var reportDateFormats = []string{"02.01.2006", "2006-01-02", "02/01/2006"}
+
+func parseReportDate(s string) (time.Time, error) {
+	var date time.Time
+	var err error
+	for _, format := range reportDateFormats {
+		if date, err = time.ParseInLocation(format, strings.TrimSpace(s), moex.Location); err == nil {
+			break
+		}
+	}
+
+	return date, err
+}
+
+// LoadNAV reads net asset value per share history from csv (`date,value' rows,
+// other rows are skipped), `source' is a local file or an url of the file
+// published by the management company
+func LoadNAV(source string) ([]NAVPoint, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, err = download(source)
+	} else {
+		data, err = ioutil.ReadFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := readCSV(data)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode `%v': %v", source, err)
+	}
+
+	var result []NAVPoint
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+
+		date, err := parseReportDate(row[0])
+		if err != nil {
+			continue
+		}
+
+		value, err := parseNumber(row[1])
+		if err != nil || value <= 0 {
+			continue
+		}
+
+		result = append(result, NAVPoint{Date: date, Value: value})
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("`%v' doesn't contain nav values", source)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+
+	return result, nil
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET `%v' failed: %v", url, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// navAt returns the last value known at `date'
+func navAt(history []NAVPoint, date time.Time) (NAVPoint, bool) {
+	var i = sort.Search(len(history), func(i int) bool {
+		return history[i].Date.After(date)
+	})
+	if i == 0 {
+		return NAVPoint{}, false
+	}
+
+	return history[i-1], true
+}
+
+// FundNAV contains closed-end fund price comparison with its net asset value
+type FundNAV struct {
+	Company string `json:"company"`
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+
+	Price       float64   `json:"price"`
+	NAV         float64   `json:"nav"` // per share
+	NAVDate     time.Time `json:"nav_date"`
+	Premium     float64   `json:"premium"`      // price to nav, percent (negative is a discount)
+	PayoutYield float64   `json:"payout_yield"` // yearly dividends to nav, percent, before tax
+	NAVChange   float64   `json:"nav_change"`   // for the last year, percent
+
+	History []NAVPoint `json:"history"` // monthly, for the last year
+}
+
+// NAVReport contains funds with known net asset value
+type NAVReport struct {
+	Funds    []*FundNAV `json:"funds"` // sorted by premium
+	Warnings []string   `json:"warnings,omitempty"`
+}
+
+// NAV compares funds prices with net asset values loaded using `nav' field,
+// prices must be filled; nav older than `maxAge' days is reported as stale
+func (p *Portfolio) NAV(maxAge int, today time.Time) *NAVReport {
+	var r = &NAVReport{}
+
+	for _, part := range p.Parts {
+		for _, a := range part.Assets {
+			var f *Fund
+			switch v := a.(type) {
+			case *Fund:
+				f = v
+			case *DivFund:
+				f = &v.Fund
+			}
+			if f == nil || f.NAV == "" {
+				continue
+			}
+
+			history, err := LoadNAV(f.NAV)
+			if err != nil {
+				r.Warnings = append(r.Warnings, fmt.Sprintf("%v: nav is unknown: %v", f, err))
+				continue
+			}
+
+			last, ok := navAt(history, today)
+			if !ok {
+				r.Warnings = append(r.Warnings, fmt.Sprintf("%v: nav is unknown at %v", f, today.Format(moex.DateFormat)))
+				continue
+			}
+			if age := int(math.Round(today.Sub(last.Date).Hours() / 24)); age > maxAge {
+				r.Warnings = append(r.Warnings, fmt.Sprintf("%v: nav is stale, it's %v days old (%v)", f, age, last.Date.Format(moex.DateFormat)))
+			}
+
+			var n = &FundNAV{
+				Company: part.Company,
+				ID:      f.ID(),
+				Name:    f.Name,
+
+				Price:       f.Price,
+				NAV:         last.Value,
+				NAVDate:     last.Date,
+				Premium:     (f.Price/last.Value - 1) * 100,
+				PayoutYield: f.RawDividend * f.DividendPeriods / last.Value * 100,
+			}
+			if prev, ok := navAt(history, today.AddDate(-1, 0, 0)); ok {
+				n.NAVChange = (last.Value/prev.Value - 1) * 100
+			}
+
+			for m := 12; m >= 0; m-- {
+				if point, ok := navAt(history, today.AddDate(0, -m, 0)); ok {
+					if len(n.History) == 0 || !n.History[len(n.History)-1].Date.Equal(point.Date) {
+						n.History = append(n.History, point)
+					}
+				}
+			}
+
+			r.Funds = append(r.Funds, n)
+		}
+	}
+
+	sort.SliceStable(r.Funds, func(i, j int) bool {
+		return r.Funds[i].Premium < r.Funds[j].Premium
+	})
+
+	return r
+}
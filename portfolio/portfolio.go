@@ -0,0 +1,146 @@
+// Package portfolio contains portfolio model (see example.json for the format),
+// its valuation and expected cash flow
+package portfolio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Portfolio contains assets grouped by broker (or any other company holding them)
+type Portfolio struct {
+	Parts           []*Part            `json:"portfolio"`
+	AssetWeightPlan map[string]float64 `json:"asset_weight_plan,omitempty"` // asset type -> percent
+
+	currency string             // report currency (rub by default)
+	rates    map[string]float64 // rub rates of currencies
+}
+
+// Part contains assets held by the company
+type Part struct {
+	Company string  `json:"company"`
+	IIS     bool    `json:"iis,omitempty"` // individual investment account
+	Assets  []Asset `json:"assets"`
+}
+
+// UnmarshalJSON decodes assets into structs of their types, unknown fields are errors
+func (p *Part) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Company string            `json:"company"`
+		IIS     bool              `json:"iis"`
+		Assets  []json.RawMessage `json:"assets"`
+	}
+	if err := decodeStrict(data, &raw); err != nil {
+		return err
+	}
+
+	p.Company, p.IIS = raw.Company, raw.IIS
+	p.Assets = nil
+
+	for i, data := range raw.Assets {
+		var base Base
+		if err := json.Unmarshal(data, &base); err != nil {
+			return fmt.Errorf("`%v' asset #%v: %v", raw.Company, i, err)
+		}
+
+		var a = newAsset(base.Type)
+		if a == nil {
+			return fmt.Errorf("`%v' asset #%v: unknown type `%v' (known: %v)", raw.Company, i, base.Type, strings.Join(Types, ", "))
+		}
+		if err := decodeStrict(data, a); err != nil {
+			return fmt.Errorf("`%v' asset #%v (%v): %v", raw.Company, i, &base, err)
+		}
+
+		p.Assets = append(p.Assets, a)
+	}
+
+	return nil
+}
+
+func decodeStrict(data []byte, v interface{}) error {
+	var dec = json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode(v)
+}
+
+// Load reads and validates portfolio from json file
+func Load(path string) (*Portfolio, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Portfolio
+	if err = decodeStrict(data, &p); err != nil {
+		return nil, fmt.Errorf("can't decode `%v': %v", path, err)
+	}
+	if err = p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid `%v': %v", path, err)
+	}
+
+	return &p, nil
+}
+
+// Validate checks all assets and weight plan, all found problems are reported at once
+func (p *Portfolio) Validate() error {
+	var errs []string
+
+	for _, part := range p.Parts {
+		for i, a := range part.Assets {
+			var err = a.validate()
+			if err == nil && a.Info().AvgPrice < 0 {
+				err = fmt.Errorf("avg price can't be negative")
+			}
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("`%v' asset #%v (%v): %v", part.Company, i, a.Info(), err))
+			}
+		}
+	}
+
+	var total float64
+	for typ, percent := range p.AssetWeightPlan {
+		if newAsset(typ) == nil {
+			errs = append(errs, fmt.Sprintf("asset weight plan: unknown type `%v'", typ))
+		}
+		if percent < 0 {
+			errs = append(errs, fmt.Sprintf("asset weight plan: negative `%v' weight", typ))
+		}
+
+		total += percent
+	}
+	if total > 100 {
+		errs = append(errs, fmt.Sprintf("asset weight plan: total weight %.2f%% exceeds 100%%", total))
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("\n\t%v", strings.Join(errs, "\n\t"))
+	}
+
+	return nil
+}
+
+// IIS returns names of companies which are individual investment accounts
+func (p *Portfolio) IIS() map[string]bool {
+	var result = make(map[string]bool)
+	for _, part := range p.Parts {
+		if part.IIS {
+			result[part.Company] = true
+		}
+	}
+
+	return result
+}
+
+// Assets returns all portfolio assets
+func (p *Portfolio) Assets() []Asset {
+	var result []Asset
+	for _, part := range p.Parts {
+		result = append(result, part.Assets...)
+	}
+
+	return result
+}
@@ -0,0 +1,190 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// boards used to get price, the first found one is used
+var goodBoards = map[string]bool{
+	"TQIF": true, "TQCB": true, "TQBR": true, "TQOB": true, "TQTF": true, "CETS": true,
+}
+
+type cachedPrice struct {
+	Price float64   `json:"price"`
+	Time  time.Time `json:"time"`
+}
+
+// UnmarshalJSON supports old cache format (price only)
+func (c *cachedPrice) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.Price); err == nil {
+		return nil
+	}
+
+	type plain cachedPrice
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// PriceCache stores fetched prices between runs
+type PriceCache struct {
+	TTL time.Duration // 0 - prices never expire
+
+	path     string
+	prices   map[string]*cachedPrice
+	modified bool
+}
+
+// LoadPriceCache reads price cache, missing file is treated as empty cache;
+// prices of the old format (without fetch time) are treated as fetched at file modification time
+func LoadPriceCache(path string, ttl time.Duration) (*PriceCache, error) {
+	var c = &PriceCache{TTL: ttl, path: path, prices: make(map[string]*cachedPrice)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Use prices from cache: `%v'", path)
+	if err = json.Unmarshal(data, &c.prices); err != nil {
+		return nil, fmt.Errorf("can't decode `%v': %v", path, err)
+	}
+
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range c.prices {
+		if p.Time.IsZero() {
+			p.Time = st.ModTime()
+		}
+	}
+
+	return c, nil
+}
+
+// Get returns cached price if it isn't expired
+func (c *PriceCache) Get(id string, now time.Time) (float64, bool) {
+	var p = c.prices[id]
+	if p == nil || p.Price == 0 {
+		return 0, false
+	}
+	if c.TTL > 0 && now.Sub(p.Time) > c.TTL {
+		return 0, false
+	}
+
+	return p.Price, true
+}
+
+// Set stores price into the cache
+func (c *PriceCache) Set(id string, price float64, now time.Time) {
+	c.prices[id] = &cachedPrice{Price: price, Time: now}
+	c.modified = true
+}
+
+// Store writes cache to the disk if it was modified
+func (c *PriceCache) Store() error {
+	if !c.modified {
+		return nil
+	}
+
+	data, err := json.Marshal(c.prices)
+	if err != nil {
+		return err
+	}
+
+	var tmp = c.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("can't write to `%v': %v", tmp, err)
+	}
+	if err = os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("can't rename `%v' -> `%v': %v", tmp, c.path, err)
+	}
+
+	c.modified = false
+
+	return nil
+}
+
+// FillPrices sets missing prices using the cache or iss
+// (crowdlending accounts with loan book don't have price)
+func (p *Portfolio) FillPrices(cache *PriceCache, now time.Time) error {
+	for _, a := range p.Assets() {
+		var info = a.Info()
+		if info.Price != 0 || info.Type == TypeCrowdLanding {
+			continue
+		}
+
+		var ok bool
+		if info.Price, ok = cache.Get(info.ID(), now); ok {
+			continue
+		}
+
+		log.Printf("fetching price for `%v'", info.ID())
+
+		price, err := FetchPrice(info)
+		if err != nil {
+			return err
+		}
+
+		info.Price = price
+		cache.Set(info.ID(), price, now)
+	}
+
+	return nil
+}
+
+// FetchPrice returns previous day close price from iss
+// (https://iss.moex.com/iss/securities/<isin>.json describes security parameters)
+func FetchPrice(b *Base) (float64, error) {
+	var engine, market, id = issMarket(b)
+
+	url := fmt.Sprintf("https://iss.moex.com/iss/engines/%v/markets/%v/securities/%v.json?iss.meta=off&iss.only=securities&securities.columns=SECID,BOARDID,SHORTNAME,PREVPRICE",
+		engine, market, id)
+
+	var response struct {
+		Securities struct {
+			Data [][]interface{} `json:"data"`
+		} `json:"securities"`
+	}
+	if err := moex.Get(url, &response); err != nil {
+		return 0, err
+	}
+
+	for _, v := range response.Securities.Data {
+		if len(v) != 4 {
+			return 0, fmt.Errorf("unknown format `%v'", v)
+		}
+		if !goodBoards[moex.String(v[1])] {
+			continue
+		}
+
+		if price := moex.Float(v[3]); price > 0 {
+			return price, nil
+		}
+	}
+
+	return 0, fmt.Errorf("can't detect `%v' price (%v)", b.ID(), url)
+}
+
+// issMarket returns iss engine, market and security id of the asset
+func issMarket(b *Base) (engine, market, id string) {
+	switch b.Type {
+	case TypeBond:
+		return "stock", "bonds", b.ISIN
+	case TypeStock, TypeETF:
+		return "stock", "shares", b.Ticker
+	case TypeCurrency:
+		return "currency", "selt", b.Ticker
+	}
+
+	return "stock", "shares", b.ISIN
+}
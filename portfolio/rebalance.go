@@ -0,0 +1,287 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// RebalanceOptions contains rebalance parameters
+type RebalanceOptions struct {
+	Cash     float64 // money available for purchases, in report currency
+	BuyOnly  bool    // don't sell anything, only cash is distributed
+	Tax      float64 // income tax (fraction) paid from sell profit
+	Slippage float64 // limit price deviation from the last price (fraction)
+}
+
+// Order is a single buy or sell order
+type Order struct {
+	Company string `json:"company"`
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+
+	Side       string  `json:"side"` // buy, sell
+	Lots       float64 `json:"lots"`
+	LotSize    float64 `json:"lot_size"`
+	LimitPrice float64 `json:"limit_price"`   // in asset currency, bond price is a percent of nominal
+	Amount     float64 `json:"amount"`        // in report currency
+	Tax        float64 `json:"tax,omitempty"` // sell profit tax
+
+	UnknownAvgPrice bool `json:"unknown_avg_price,omitempty"` // sell tax can't be calculated
+}
+
+// TypeRebalance contains asset type value before and after orders execution
+type TypeRebalance struct {
+	Type   string  `json:"type"`
+	Value  float64 `json:"value"`
+	Target float64 `json:"target"`
+	After  float64 `json:"after"`
+}
+
+// Rebalance contains orders moving portfolio towards asset weight plan
+type Rebalance struct {
+	Orders []*Order         `json:"orders"`
+	Types  []*TypeRebalance `json:"types"`
+
+	Currency string   `json:"currency"`
+	Cash     float64  `json:"cash"`      // available before orders
+	CashLeft float64  `json:"cash_left"` // not distributed because of lot sizes
+	Tax      float64  `json:"tax"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// tradable is an asset which may be bought or sold by lots
+type tradable struct {
+	company string
+	asset   Asset
+
+	lots     float64 // held lots
+	lotSize  float64 // units per lot
+	lotValue float64
+	lotTax   float64 // tax paid for one sold lot
+}
+
+// newTradable creates tradable asset, `rate' converts asset currency into report currency
+func newTradable(company string, a Asset, tax, rate float64) *tradable {
+	var t = &tradable{company: company, asset: a, lotSize: 1}
+
+	switch v := a.(type) {
+	case *Bond:
+		t.lots, t.lotValue = v.Count, v.Nominal*v.Price/100.0
+	case *Stock:
+		t.lots, t.lotSize, t.lotValue = v.LotCount, v.LotSize, v.Price*v.LotSize
+	case *Fund:
+		t.lots, t.lotValue = v.Count, v.Price
+	case *DivFund:
+		t.lots, t.lotValue = v.Count, v.Price
+	case *Currency:
+		t.lots, t.lotValue = v.Count, v.Price
+	default:
+		return nil
+	}
+
+	t.lotValue *= rate
+
+	var info = a.Info()
+	if info.AvgPrice > 0 && info.Price > info.AvgPrice {
+		t.lotTax = t.lotValue * (1 - info.AvgPrice/info.Price) * tax
+	}
+
+	return t
+}
+
+func (t *tradable) order(side string, lots, slippage float64) *Order {
+	var info = t.asset.Info()
+
+	var price = info.Price * (1 + slippage)
+	if side == "sell" {
+		price = info.Price * (1 - slippage)
+	}
+
+	var o = &Order{
+		Company: t.company,
+		Type:    info.Type,
+		ID:      info.ID(),
+		Name:    info.Name,
+
+		Side:       side,
+		Lots:       lots,
+		LotSize:    t.lotSize,
+		LimitPrice: roundPrice(price),
+		Amount:     lots * t.lotValue,
+	}
+	if side == "sell" {
+		o.Tax = lots * t.lotTax
+		o.UnknownAvgPrice = info.AvgPrice == 0
+	}
+
+	return o
+}
+
+// roundPrice rounds price to kopecks (cheap instruments keep more digits)
+func roundPrice(price float64) float64 {
+	var scale = 100.0
+	if price < 10 {
+		scale = 10000.0
+	}
+
+	return math.Round(price*scale) / scale
+}
+
+// Rebalance generates orders moving asset types weights towards the plan,
+// types without plan are kept as is; orders are created for already held instruments only:
+// the cheapest ones in tax terms are sold first, purchases are spread proportionally to the current value
+func (p *Portfolio) Rebalance(opts RebalanceOptions) (*Rebalance, error) {
+	if len(p.AssetWeightPlan) == 0 {
+		return nil, fmt.Errorf("asset weight plan is empty")
+	}
+
+	var r = &Rebalance{Currency: p.Currency(), Cash: opts.Cash}
+
+	var total float64
+	var type2tradables = make(map[string][]*tradable)
+	var type2stat = make(map[string]*TypeRebalance)
+	for _, part := range p.Parts {
+		for _, a := range part.Assets {
+			var typ = a.Info().Type
+
+			s := type2stat[typ]
+			if s == nil {
+				s = &TypeRebalance{Type: typ}
+				type2stat[typ] = s
+			}
+			s.Value += p.value(a)
+			total += p.value(a)
+
+			if t := newTradable(part.Company, a, opts.Tax, p.convert(a, 1)); t != nil && t.lotValue > 0 {
+				type2tradables[typ] = append(type2tradables[typ], t)
+			}
+		}
+	}
+	for typ := range p.AssetWeightPlan {
+		if type2stat[typ] == nil {
+			type2stat[typ] = &TypeRebalance{Type: typ}
+		}
+	}
+
+	for typ, s := range type2stat {
+		s.Target, s.After = s.Value, s.Value
+		if percent, ok := p.AssetWeightPlan[typ]; ok {
+			s.Target = (total + opts.Cash) / 100 * percent
+		}
+
+		r.Types = append(r.Types, s)
+	}
+	sort.Slice(r.Types, func(i, j int) bool {
+		return r.Types[i].Type < r.Types[j].Type
+	})
+
+	var cash = opts.Cash
+	if !opts.BuyOnly {
+		for _, s := range r.Types {
+			if s.After <= s.Target {
+				continue
+			}
+
+			var tradables = type2tradables[s.Type]
+			sort.SliceStable(tradables, func(i, j int) bool {
+				var ti, tj = tradables[i].lotTax / tradables[i].lotValue, tradables[j].lotTax / tradables[j].lotValue
+				if ti != tj {
+					return ti < tj
+				}
+
+				return tradables[i].lots*tradables[i].lotValue > tradables[j].lots*tradables[j].lotValue
+			})
+
+			for _, t := range tradables {
+				var lots = math.Min(t.lots, math.Floor((s.After-s.Target)/t.lotValue))
+				if lots <= 0 {
+					continue
+				}
+
+				var o = t.order("sell", lots, opts.Slippage)
+				r.Orders = append(r.Orders, o)
+
+				s.After -= o.Amount
+				cash += o.Amount - o.Tax
+				r.Tax += o.Tax
+			}
+		}
+	}
+
+	var gaps float64
+	for _, s := range r.Types {
+		if s.Target > s.After {
+			gaps += s.Target - s.After
+		}
+	}
+
+	for _, s := range r.Types {
+		if s.Target <= s.After || gaps <= 0 {
+			continue
+		}
+
+		var tradables = type2tradables[s.Type]
+		if len(tradables) == 0 {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("there is no `%v' instrument to buy", s.Type))
+			continue
+		}
+
+		// the gap is reduced when cash isn't enough to close all gaps
+		var budget = (s.Target - s.After) * math.Min(1, cash/gaps)
+
+		var value float64
+		for _, t := range tradables {
+			value += t.lots * t.lotValue
+		}
+
+		var left = budget
+		var buy = make(map[*tradable]float64)
+		for _, t := range tradables {
+			var share = 1.0 / float64(len(tradables))
+			if value > 0 {
+				share = t.lots * t.lotValue / value
+			}
+
+			var lots = math.Floor(math.Min(budget*share, left) / t.lotValue)
+			if lots > 0 {
+				buy[t] += lots
+				left -= lots * t.lotValue
+			}
+		}
+
+		// rounding leftovers are spent on the cheapest lots
+		var cheapest = append([]*tradable{}, tradables...)
+		sort.SliceStable(cheapest, func(i, j int) bool {
+			return cheapest[i].lotValue < cheapest[j].lotValue
+		})
+		for _, t := range cheapest {
+			var lots = math.Floor(left / t.lotValue)
+			if lots > 0 {
+				buy[t] += lots
+				left -= lots * t.lotValue
+			}
+		}
+
+		for _, t := range tradables {
+			if buy[t] == 0 {
+				continue
+			}
+
+			var o = t.order("buy", buy[t], opts.Slippage)
+			r.Orders = append(r.Orders, o)
+
+			s.After += o.Amount
+		}
+	}
+
+	r.CashLeft = cash
+	for _, o := range r.Orders {
+		if o.Side == "buy" {
+			r.CashLeft -= o.Amount
+		}
+	}
+
+	return r, nil
+}
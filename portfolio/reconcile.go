@@ -0,0 +1,261 @@
+package portfolio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// reconcile statuses
+const (
+	StatusNew       = "new"       // position is missing in portfolio
+	StatusMissing   = "missing"   // asset is missing in report
+	StatusChanged   = "changed"   // quantities differ
+	StatusAmbiguous = "ambiguous" // several assets match the position, it can't be updated
+)
+
+// Difference describes portfolio and broker report mismatch
+type Difference struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name,omitempty"`
+	Type     string  `json:"type,omitempty"`
+	Status   string  `json:"status"`
+	Have     float64 `json:"have"`     // number of securities in portfolio
+	Imported float64 `json:"imported"` // number of securities in report
+}
+
+// units returns number of securities, false is returned for non exchange assets
+func units(a Asset) (float64, bool) {
+	switch v := a.(type) {
+	case *Bond:
+		return v.Count, true
+	case *Stock:
+		return v.Count(), true
+	case *Fund:
+		return v.Count, true
+	case *DivFund:
+		return v.Count, true
+	case *Currency:
+		return v.Count, true
+	}
+
+	return 0, false
+}
+
+func setUnits(a Asset, n float64) {
+	switch v := a.(type) {
+	case *Bond:
+		v.Count = n
+	case *Stock:
+		v.LotCount = n / v.LotSize
+	case *Fund:
+		v.Count = n
+	case *DivFund:
+		v.Count = n
+	case *Currency:
+		v.Count = n
+	}
+}
+
+func (p *Portfolio) part(company string) *Part {
+	for _, part := range p.Parts {
+		if part.Company == company {
+			return part
+		}
+	}
+
+	return nil
+}
+
+// match returns assets of the part matching positions (by isin or ticker)
+func (part *Part) match(positions []*Position) (map[*Position][]Asset, map[Asset]bool) {
+	var result = make(map[*Position][]Asset)
+	var matched = make(map[Asset]bool)
+
+	for _, pos := range positions {
+		for _, a := range part.Assets {
+			if _, ok := units(a); !ok {
+				continue
+			}
+
+			var info = a.Info()
+			if (pos.ISIN != "" && info.ISIN == pos.ISIN) || (pos.Ticker != "" && info.Ticker == pos.Ticker) {
+				result[pos] = append(result[pos], a)
+				matched[a] = true
+			}
+		}
+	}
+
+	return result, matched
+}
+
+// Reconcile compares `company' assets with broker report positions
+func (p *Portfolio) Reconcile(company string, positions []*Position) []*Difference {
+	var part = p.part(company)
+	if part == nil {
+		part = &Part{Company: company}
+	}
+
+	var result []*Difference
+	var pos2assets, matched = part.match(positions)
+	for _, pos := range positions {
+		var d = &Difference{ID: pos.ID(), Name: pos.Name, Type: pos.Type, Imported: pos.Quantity}
+
+		var assets = pos2assets[pos]
+		for _, a := range assets {
+			n, _ := units(a)
+			d.Have += n
+			d.Type = a.Info().Type
+		}
+
+		switch {
+		case len(assets) == 0:
+			d.Status = StatusNew
+		case len(assets) > 1:
+			d.Status = StatusAmbiguous
+		case d.Have != d.Imported:
+			d.Status = StatusChanged
+		default:
+			continue
+		}
+
+		result = append(result, d)
+	}
+
+	// currencies are kept by import, so they aren't reported as missing
+	for _, a := range part.Assets {
+		n, ok := units(a)
+		if !ok || matched[a] || a.Info().Type == TypeCurrency {
+			continue
+		}
+
+		var info = a.Info()
+		result = append(result, &Difference{ID: info.ID(), Name: info.Name, Type: info.Type, Status: StatusMissing, Have: n})
+	}
+
+	return result
+}
+
+// ApplyImport updates `company' assets by broker report: quantities are updated,
+// missing securities are removed, new ones are described using iss;
+// ambiguous positions and positions which can't be described are returned as warnings
+func (p *Portfolio) ApplyImport(company string, positions []*Position) ([]string, error) {
+	var part = p.part(company)
+	if part == nil {
+		part = &Part{Company: company}
+		p.Parts = append(p.Parts, part)
+	}
+
+	var warnings []string
+	var pos2assets, matched = part.match(positions)
+
+	// currencies are kept, they are rarely listed in reports securities tables
+	var assets []Asset
+	for _, a := range part.Assets {
+		if _, ok := units(a); ok && !matched[a] && a.Info().Type != TypeCurrency {
+			continue
+		}
+
+		assets = append(assets, a)
+	}
+
+	for _, pos := range positions {
+		switch matches := pos2assets[pos]; len(matches) {
+		case 0:
+			a, err := newImportedAsset(pos)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("can't add `%v': %v", pos.ID(), err))
+				continue
+			}
+
+			assets = append(assets, a)
+		case 1:
+			setUnits(matches[0], pos.Quantity)
+		default:
+			warnings = append(warnings, fmt.Sprintf("`%v' matches %v assets, update it manually", pos.ID(), len(matches)))
+		}
+	}
+
+	part.Assets = assets
+
+	return warnings, p.Validate()
+}
+
+// newImportedAsset creates asset using security description from iss
+func newImportedAsset(pos *Position) (Asset, error) {
+	d, err := moex.DownloadDescription(pos.ID())
+	if err != nil {
+		return nil, err
+	}
+
+	var typ = pos.Type
+	switch d.Group {
+	case "stock_bonds":
+		typ = TypeBond
+	case "stock_shares", "stock_dr":
+		typ = TypeStock
+	case "stock_etf":
+		typ = TypeETF
+	case "stock_ppif":
+		typ = TypeFund
+	}
+
+	var base = Base{Type: typ, ISIN: d.ISIN, Name: d.ShortName}
+	if base.Name == "" {
+		base.Name = pos.Name
+	}
+
+	switch typ {
+	case TypeBond:
+		var nominal = d.FaceValue
+		if nominal == 0 {
+			nominal = pos.Nominal
+		}
+
+		return &Bond{
+			Base:         base,
+			Count:        pos.Quantity,
+			Nominal:      nominal,
+			Percent:      d.CouponPercent,
+			MaturityDate: d.MaturityDate.Format(moex.DateFormat),
+		}, nil
+	case TypeStock, TypeETF:
+		base.Ticker = d.SecID
+
+		lotSize, err := moex.DownloadLotSize("stock", "shares", d.SecID, goodBoards)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Stock{Base: base, LotCount: pos.Quantity / lotSize, LotSize: lotSize}, nil
+	case TypeFund:
+		return &Fund{Base: base, Count: pos.Quantity}, nil
+	}
+
+	return nil, fmt.Errorf("unknown security type (group: `%v', type: `%v')", d.Group, d.Type)
+}
+
+// Store writes portfolio into json file
+func (p *Portfolio) Store(path string) error {
+	var b bytes.Buffer
+	var enc = json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(p); err != nil {
+		return err
+	}
+
+	var tmp = path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("can't write to `%v': %v", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("can't rename `%v' -> `%v': %v", tmp, path, err)
+	}
+
+	return nil
+}
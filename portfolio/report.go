@@ -0,0 +1,118 @@
+package portfolio
+
+import (
+	"sort"
+	"time"
+)
+
+// TypeStat contains value and cash flow of all assets of the single type
+type TypeStat struct {
+	Type    string  `json:"type"`
+	Value   float64 `json:"value"`
+	Percent float64 `json:"percent"` // part of portfolio value
+
+	Plan *Plan `json:"plan,omitempty"`
+
+	CashFlow        float64 `json:"cash_flow"`         // yearly, after tax
+	CashFlowPercent float64 `json:"cash_flow_percent"` // part of portfolio cash flow
+	NetYield        float64 `json:"net_yield"`         // percent
+	DirtyYield      float64 `json:"dirty_yield"`       // percent, before tax
+}
+
+// Plan describes difference between current and planned type weight
+type Plan struct {
+	Percent     float64 `json:"percent"`
+	Value       float64 `json:"value"`
+	Diff        float64 `json:"diff"` // current - planned value
+	DiffPercent float64 `json:"diff_percent"`
+}
+
+// Report contains portfolio summary
+type Report struct {
+	Currency        string  `json:"currency"`
+	Value           float64 `json:"value"`
+	CashFlow        float64 `json:"cash_flow"` // yearly, after tax
+	MonthlyCashFlow float64 `json:"monthly_cash_flow"`
+	Yield           float64 `json:"yield"` // percent
+
+	Types     []*TypeStat      `json:"types"` // sorted by value
+	Dividends []*DividendYield `json:"dividends,omitempty"`
+	Expired   []string         `json:"expired,omitempty"`
+}
+
+// Report calculates portfolio summary, prices must be filled,
+// `tax' is a fraction used for cash flow
+func (p *Portfolio) Report(tax float64, today time.Time) *Report {
+	var r = Report{Currency: p.Currency()}
+	var type2stat = make(map[string]*TypeStat)
+
+	var stat = func(typ string) *TypeStat {
+		s := type2stat[typ]
+		if s == nil {
+			s = &TypeStat{Type: typ}
+			type2stat[typ] = s
+
+			r.Types = append(r.Types, s)
+		}
+
+		return s
+	}
+
+	var expired = make(map[string]bool)
+	for _, a := range p.Assets() {
+		if b, ok := a.(*Bond); ok && b.IsExpired(today) && !expired[b.ISIN] {
+			expired[b.ISIN] = true
+			r.Expired = append(r.Expired, b.ISIN)
+		}
+
+		var s = stat(a.Info().Type)
+		s.Value += p.value(a)
+		s.CashFlow += p.cashFlow(a, tax)
+
+		r.Value += p.value(a)
+		r.CashFlow += p.cashFlow(a, tax)
+	}
+
+	// planned but missing types are reported too
+	for typ := range p.AssetWeightPlan {
+		stat(typ)
+	}
+
+	r.Dividends = p.dividendYields()
+	r.MonthlyCashFlow = r.CashFlow / 12
+	if r.Value > 0 {
+		r.Yield = r.CashFlow / r.Value * 100
+	}
+
+	for _, s := range r.Types {
+		if r.Value > 0 {
+			s.Percent = s.Value / r.Value * 100
+		}
+		if r.CashFlow > 0 {
+			s.CashFlowPercent = s.CashFlow / r.CashFlow * 100
+		}
+		if s.Value > 0 {
+			s.NetYield = s.CashFlow / s.Value * 100
+			s.DirtyYield = s.NetYield / (1 - tax)
+		}
+
+		if percent, ok := p.AssetWeightPlan[s.Type]; ok {
+			s.Plan = &Plan{Percent: percent, Value: r.Value / 100 * percent}
+			s.Plan.Diff = s.Value - s.Plan.Value
+			if s.Plan.Value > 0 {
+				s.Plan.DiffPercent = s.Plan.Diff / s.Plan.Value * 100
+			}
+		}
+	}
+
+	sort.SliceStable(r.Types, func(i, j int) bool {
+		if r.Types[i].Value != r.Types[j].Value {
+			return r.Types[i].Value > r.Types[j].Value
+		}
+
+		return r.Types[i].Type < r.Types[j].Type
+	})
+	sort.Strings(r.Expired)
+
+	return &r
+}
@@ -0,0 +1,327 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// CashFlow is a dated money flow, negative amounts are investments
+type CashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+// XIRR returns annual money-weighted return (fraction) of the flows
+func XIRR(flows []CashFlow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, fmt.Errorf("at least two flows are required")
+	}
+
+	var first = flows[0].Date
+	for _, f := range flows {
+		if f.Date.Before(first) {
+			first = f.Date
+		}
+	}
+
+	var npv = func(rate float64) float64 {
+		var result float64
+		for _, f := range flows {
+			var years = math.Round(f.Date.Sub(first).Hours()/24) / 365 // dst changes hours
+			result += f.Amount / math.Pow(1+rate, years)
+		}
+
+		return result
+	}
+
+	// look for the bracket around the root, then bisect it
+	var lo, hi = -0.99, 1.0
+	for npv(lo)*npv(hi) > 0 {
+		if hi > 1e6 {
+			return 0, fmt.Errorf("flows don't change sign")
+		}
+
+		hi *= 2
+	}
+
+	for i := 0; i < 200 && hi-lo > 1e-10; i++ {
+		var mid = (lo + hi) / 2
+		if npv(lo)*npv(mid) <= 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	return (lo + hi) / 2, nil
+}
+
+// Return is a ledger entity (asset, company or whole portfolio) return
+type Return struct {
+	Company string `json:"company,omitempty"`
+	ID      string `json:"id,omitempty"`
+
+	Invested float64  `json:"invested"` // purchases or deposits
+	Received float64  `json:"received"` // sales, coupons, dividends or withdrawals
+	Value    float64  `json:"value"`    // current value
+	Profit   float64  `json:"profit"`
+	XIRR     *float64 `json:"xirr"` // percent, nil if it can't be calculated
+
+	flows []CashFlow
+}
+
+func (r *Return) add(date time.Time, amount float64) {
+	if amount < 0 {
+		r.Invested -= amount
+	} else {
+		r.Received += amount
+	}
+
+	r.flows = append(r.flows, CashFlow{Date: date, Amount: amount})
+}
+
+func (r *Return) finish(today time.Time, value float64) {
+	r.Value = value
+	r.Profit = r.Received + r.Value - r.Invested
+
+	var flows = append(r.flows, CashFlow{Date: today, Amount: value})
+
+	if xirr, err := XIRR(flows); err == nil {
+		xirr *= 100
+		r.XIRR = &xirr
+	}
+}
+
+// Returns contains ledger returns
+type Returns struct {
+	Holdings  []*Holding `json:"holdings"`
+	Assets    []*Return  `json:"assets"`
+	Companies []*Return  `json:"companies"`
+	Total     *Return    `json:"total"`
+
+	TWR           float64 `json:"twr"`            // percent, whole period
+	TWRAnnualized float64 `json:"twr_annualized"` // percent
+
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Valuer returns current value of `quantity' securities `id' held by `company',
+// false is returned if the price is unknown
+type Valuer func(company, id string, quantity float64) (float64, bool)
+
+// PastValuer returns value of `quantity' securities `id' held by `company' at `date' close,
+// false is returned if the price is unknown
+type PastValuer func(company, id string, quantity float64, date time.Time) (float64, bool)
+
+// Returns calculates money-weighted (xirr) returns of assets, companies (by deposits and withdrawals)
+// and whole portfolio, and time-weighted return of the portfolio; holdings are valued by `value'
+// (and by `past' at deposit and withdrawal dates), the last deal price is used if it fails
+func (l *Ledger) Returns(value Valuer, past PastValuer, today time.Time) (*Returns, error) {
+	if len(l.Transactions) == 0 {
+		return nil, fmt.Errorf("ledger is empty")
+	}
+
+	var r = &Returns{Total: &Return{}}
+
+	var assets = make(map[holdingKey]*Return)
+	var companies = make(map[string]*Return)
+	var asset = func(t *Transaction) *Return {
+		var key = holdingKey{t.Company, t.ID}
+		if assets[key] == nil {
+			assets[key] = &Return{Company: t.Company, ID: t.ID}
+			r.Assets = append(r.Assets, assets[key])
+		}
+
+		return assets[key]
+	}
+	var company = func(name string) *Return {
+		if companies[name] == nil {
+			companies[name] = &Return{Company: name}
+			r.Companies = append(r.Companies, companies[name])
+		}
+
+		return companies[name]
+	}
+
+	var s = newLedgerState()
+
+	// time-weighted return is chained between external flows,
+	// the portfolio is valued using close prices of the flows dates
+	var twr, valueAfter = 1.0, 0.0
+	var unknown = make(map[holdingKey]bool)
+	var estimate = func(date time.Time) float64 {
+		var result float64
+		for _, c := range s.cash {
+			result += c
+		}
+		for key, h := range s.holdings {
+			if h.Quantity <= 0 {
+				continue
+			}
+
+			v, ok := past(h.Company, h.ID, h.Quantity, date)
+			if !ok {
+				v = h.Quantity * h.LastPrice
+				if !unknown[key] {
+					unknown[key] = true
+					r.Warnings = append(r.Warnings, fmt.Sprintf("`%v' (%v) close prices are unknown, the last deal price is used by twr", h.ID, h.Company))
+				}
+			}
+
+			result += v
+		}
+
+		return result
+	}
+
+	for _, t := range l.Transactions {
+		company(t.Company)
+
+		switch t.Operation {
+		case OpBuy:
+			asset(t).add(t.Date, -t.Amount-t.Fee)
+		case OpSell:
+			asset(t).add(t.Date, t.Amount-t.Fee)
+		case OpCoupon, OpDividend:
+			asset(t).add(t.Date, t.Amount-t.Fee)
+		case OpFee:
+			if t.ID != "" {
+				asset(t).add(t.Date, -t.Amount-t.Fee)
+			}
+		case OpDeposit, OpWithdrawal:
+			if valueAfter > 0 {
+				twr *= estimate(t.Date) / valueAfter
+			}
+
+			var amount = t.Amount
+			if t.Operation == OpDeposit {
+				amount = -amount
+			}
+
+			company(t.Company).add(t.Date, amount-t.Fee)
+			r.Total.add(t.Date, amount-t.Fee)
+		}
+
+		s.apply(t)
+
+		if isExternal(t) {
+			valueAfter = estimate(t.Date)
+		}
+	}
+
+	var companyValue = make(map[string]float64)
+	for _, key := range s.order {
+		var h = s.holdings[key]
+		if h.Quantity > 0 {
+			r.Holdings = append(r.Holdings, h)
+		}
+
+		var v float64
+		if h.Quantity > 0 {
+			var ok bool
+			if v, ok = value(h.Company, h.ID, h.Quantity); !ok {
+				v = h.Quantity * h.LastPrice
+			}
+		}
+
+		if a := assets[key]; a != nil {
+			a.finish(today, v)
+		}
+		companyValue[h.Company] += v
+	}
+
+	for _, a := range r.Assets {
+		if _, ok := s.holdings[holdingKey{a.Company, a.ID}]; !ok {
+			// coupons or fees of securities which weren't bought through the ledger
+			a.finish(today, 0)
+		}
+	}
+
+	var total float64
+	for _, c := range r.Companies {
+		var v = companyValue[c.Company] + s.cash[c.Company]
+		c.finish(today, v)
+
+		total += v
+	}
+	r.Total.finish(today, total)
+
+	if valueAfter > 0 {
+		twr *= total / valueAfter
+	}
+
+	r.TWR = (twr - 1) * 100
+	if years := today.Sub(l.Transactions[0].Date).Hours() / 24 / 365; years > 0 && twr > 0 {
+		r.TWRAnnualized = (math.Pow(twr, 1/years) - 1) * 100
+	}
+
+	sort.SliceStable(r.Assets, func(i, j int) bool {
+		if r.Assets[i].Company != r.Assets[j].Company {
+			return r.Assets[i].Company < r.Assets[j].Company
+		}
+
+		return r.Assets[i].ID < r.Assets[j].ID
+	})
+
+	return r, nil
+}
+
+func isExternal(t *Transaction) bool {
+	return t.Operation == OpDeposit || t.Operation == OpWithdrawal
+}
+
+// Valuer returns valuer using portfolio assets prices (they must be filled),
+// values are in rubles as ledger amounts
+func (p *Portfolio) Valuer() Valuer {
+	return func(company, id string, quantity float64) (float64, bool) {
+		var part = p.part(company)
+		if part == nil {
+			return 0, false
+		}
+
+		for _, a := range part.Assets {
+			var info = a.Info()
+			if info.ISIN != id && info.Ticker != id {
+				continue
+			}
+
+			if n, ok := units(a); ok && n > 0 {
+				return p.toRUB(a, a.Value()) / n * quantity, true
+			}
+		}
+
+		return 0, false
+	}
+}
+
+// PastValuer returns valuer using iss close prices and currency rates since `from',
+// values are in rubles as ledger amounts; holdings missing in the portfolio are unknown
+func (p *Portfolio) PastValuer(from, till time.Time) PastValuer {
+	var h = &history{p: p, quotes: make(map[string]moex.Quotes), rates: make(map[string]moex.Quotes)}
+
+	return func(company, id string, quantity float64, date time.Time) (float64, bool) {
+		var a = p.asset(company, id)
+		if a == nil {
+			return 0, false
+		}
+
+		var info = a.Info()
+		h.download(a, from, till)
+
+		price, ok := h.quotes[info.ID()].At(date)
+		if !ok {
+			return 0, false
+		}
+
+		var v = price * quantity
+		if b, ok := a.(*Bond); ok {
+			v = b.Nominal * price / 100.0 * quantity
+		}
+
+		return v * h.rate(info.Currency, date), true
+	}
+}
@@ -0,0 +1,79 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestXIRR(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		flows []CashFlow
+		xirr  float64
+		fails bool
+	}{
+		{
+			name: "excel example",
+			flows: []CashFlow{
+				{Date: date("2008-01-01"), Amount: -10000},
+				{Date: date("2008-03-01"), Amount: 2750},
+				{Date: date("2008-10-30"), Amount: 4250},
+				{Date: date("2009-02-15"), Amount: 3250},
+				{Date: date("2009-04-01"), Amount: 2750},
+			},
+			xirr: 0.373362535,
+		},
+		{
+			name: "one year",
+			flows: []CashFlow{
+				{Date: date("2021-01-01"), Amount: -1000},
+				{Date: date("2022-01-01"), Amount: 1100},
+			},
+			xirr: 0.1,
+		},
+		{
+			name: "loss",
+			flows: []CashFlow{
+				{Date: date("2021-01-01"), Amount: -1000},
+				{Date: date("2022-01-01"), Amount: 900},
+			},
+			xirr: -0.1,
+		},
+		{
+			name: "unordered",
+			flows: []CashFlow{
+				{Date: date("2022-01-01"), Amount: 1100},
+				{Date: date("2021-01-01"), Amount: -1000},
+			},
+			xirr: 0.1,
+		},
+		{
+			name:  "single flow",
+			flows: []CashFlow{{Date: date("2021-01-01"), Amount: -1000}},
+			fails: true,
+		},
+		{
+			name: "no income",
+			flows: []CashFlow{
+				{Date: date("2021-01-01"), Amount: -1000},
+				{Date: date("2022-01-01"), Amount: -100},
+			},
+			fails: true,
+		},
+	} {
+		xirr, err := XIRR(tt.flows)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("%v: error expected, got %v", tt.name, xirr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+		} else if math.Abs(xirr-tt.xirr) > 1e-6 {
+			t.Errorf("%v: xirr is %v, expected %v", tt.name, xirr, tt.xirr)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package portfolio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// exposure kinds
+const (
+	ExposureEmitent = "emitent"
+	ExposureCompany = "company"
+	ExposureType    = "type"
+	ExposureSector  = "sector"
+)
+
+// Limit is a max exposure, zero fields are disabled
+type Limit struct {
+	Percent float64 `json:"percent,omitempty"` // of portfolio value
+	Value   float64 `json:"value,omitempty"`   // in rubles, e.g. deposit insurance limit
+}
+
+// RiskLimits contains default limits of each exposure kind and their overrides
+// (emitents are identified by inn)
+type RiskLimits struct {
+	Emitent Limit `json:"emitent"`
+	Company Limit `json:"company"`
+	Type    Limit `json:"type"`
+	Sector  Limit `json:"sector"`
+
+	Emitents  map[string]Limit `json:"emitents,omitempty"`
+	Companies map[string]Limit `json:"companies,omitempty"`
+	Types     map[string]Limit `json:"types,omitempty"`
+	Sectors   map[string]Limit `json:"sectors,omitempty"`
+}
+
+// LoadRiskLimits reads limits from json file
+func LoadRiskLimits(path string) (*RiskLimits, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var l RiskLimits
+	if err = decodeStrict(data, &l); err != nil {
+		return nil, fmt.Errorf("can't decode `%v': %v", path, err)
+	}
+
+	return &l, nil
+}
+
+func (l *RiskLimits) limit(kind, key string) Limit {
+	var def Limit
+	var overrides map[string]Limit
+
+	switch kind {
+	case ExposureEmitent:
+		def, overrides = l.Emitent, l.Emitents
+	case ExposureCompany:
+		def, overrides = l.Company, l.Companies
+	case ExposureType:
+		def, overrides = l.Type, l.Types
+	case ExposureSector:
+		def, overrides = l.Sector, l.Sectors
+	}
+
+	if v, ok := overrides[key]; ok {
+		return v
+	}
+
+	return def
+}
+
+// Exposure is a portfolio part sharing the same emitent, company, type or sector
+type Exposure struct {
+	Kind    string  `json:"kind"`
+	Key     string  `json:"key"` // inn for emitents
+	Title   string  `json:"title,omitempty"`
+	Value   float64 `json:"value"`
+	Percent float64 `json:"percent"`
+
+	Limit  Limit   `json:"limit"`
+	Breach bool    `json:"breach"`
+	Excess float64 `json:"excess,omitempty"` // value above the limit
+}
+
+// RiskReport contains exposures sorted by value and limits breaches
+type RiskReport struct {
+	Currency  string      `json:"currency"`
+	Value     float64     `json:"value"`
+	Exposures []*Exposure `json:"exposures"`
+	Breaches  []*Exposure `json:"breaches"`
+}
+
+// isFund checks whether asset type is a fund, its inn is management company one
+func isFund(typ string) bool {
+	return typ == TypeFund || typ == TypeETF || typ == TypeDivFund
+}
+
+// resolveEmitents fills missing inn of exchange traded assets (except funds) using iss
+func (p *Portfolio) resolveEmitents() {
+	for _, a := range p.Assets() {
+		var info = a.Info()
+		if info.INN != "" || info.Type == TypeCurrency || isFund(info.Type) || info.ID() == "" {
+			continue
+		}
+		if _, ok := units(a); !ok {
+			continue
+		}
+
+		e, err := moex.DownloadEmitent(info.ID())
+		if err != nil {
+			log.Printf("can't detect `%v' emitent: %v", info.ID(), err)
+			continue
+		}
+
+		info.INN, info.Emitent = e.INN, e.Title
+	}
+}
+
+// Risk calculates exposures and checks them against limits, prices must be filled;
+// missing emitents are detected using iss, assets without emitent (e.g. crowdlending)
+// are treated as issued by their company, currencies and funds don't have emitent
+func (p *Portfolio) Risk(limits *RiskLimits) *RiskReport {
+	p.resolveEmitents()
+
+	var r = &RiskReport{Currency: p.Currency()}
+
+	type key struct {
+		kind, key string
+	}
+	var exposures = make(map[key]*Exposure)
+	var add = func(kind, k, title string, value float64) {
+		e := exposures[key{kind, k}]
+		if e == nil {
+			e = &Exposure{Kind: kind, Key: k, Title: title}
+			exposures[key{kind, k}] = e
+			r.Exposures = append(r.Exposures, e)
+		}
+
+		e.Value += value
+	}
+
+	for _, part := range p.Parts {
+		for _, a := range part.Assets {
+			var info = a.Info()
+			var value = p.value(a)
+			r.Value += value
+
+			add(ExposureCompany, part.Company, "", value)
+			add(ExposureType, info.Type, "", value)
+
+			var sector = info.Sector
+			if sector == "" {
+				sector = "unknown"
+			}
+			add(ExposureSector, sector, "", value)
+
+			switch {
+			case info.Type == TypeCurrency || isFund(info.Type):
+			case info.INN != "":
+				add(ExposureEmitent, info.INN, info.Emitent, value)
+			case info.ID() != "":
+				add(ExposureEmitent, info.ID(), info.Name, value)
+			default:
+				add(ExposureEmitent, part.Company, part.Company, value)
+			}
+		}
+	}
+
+	for _, e := range r.Exposures {
+		if r.Value > 0 {
+			e.Percent = e.Value / r.Value * 100
+		}
+
+		e.Limit = limits.limit(e.Kind, e.Key)
+		if e.Limit.Percent > 0 && e.Percent > e.Limit.Percent {
+			e.Breach = true
+			e.Excess = e.Value - r.Value/100*e.Limit.Percent
+		}
+		if limit := e.Limit.Value / p.rate(p.currency); limit > 0 && e.Value > limit {
+			e.Breach = true
+			if excess := e.Value - limit; excess > e.Excess {
+				e.Excess = excess
+			}
+		}
+
+		if e.Breach {
+			r.Breaches = append(r.Breaches, e)
+		}
+	}
+
+	for _, list := range [][]*Exposure{r.Exposures, r.Breaches} {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Kind != list[j].Kind {
+				return list[i].Kind < list[j].Kind
+			}
+
+			return list[i].Value > list[j].Value
+		})
+	}
+
+	return r
+}
+
+// String returns limits description
+func (l Limit) String() string {
+	switch {
+	case l.Percent > 0 && l.Value > 0:
+		return fmt.Sprintf("%.2f%%, %.2f RUB", l.Percent, l.Value)
+	case l.Percent > 0:
+		return fmt.Sprintf("%.2f%%", l.Percent)
+	case l.Value > 0:
+		return fmt.Sprintf("%.2f RUB", l.Value)
+	}
+
+	return "none"
+}
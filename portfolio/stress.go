@@ -0,0 +1,187 @@
+package portfolio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// Scenario is a market shock applied to the whole portfolio
+type Scenario struct {
+	Name string `json:"name"`
+
+	RateShift float64 `json:"rate_shift_bp,omitempty"` // bonds yields change, basis points
+
+	// ruble change against all currencies and metals, e.g. -20 raises their rates by 25%
+	RUB float64            `json:"rub_percent,omitempty"`
+	FX  map[string]float64 `json:"fx_percent,omitempty"` // currency rate change, overrides `rub_percent'
+
+	Equity    float64 `json:"equity_percent,omitempty"`   // stocks and funds move by their beta
+	Dividends float64 `json:"dividend_percent,omitempty"` // stocks and dividend funds payments change
+}
+
+// DefaultScenarios are used if scenarios aren't specified
+var DefaultScenarios = []*Scenario{
+	{Name: "key rate +300bp", RateShift: 300},
+	{Name: "rub -20%", RUB: -20},
+	{Name: "equities -30%", Equity: -30},
+	{Name: "crisis", RateShift: 300, RUB: -20, Equity: -30, Dividends: -30},
+}
+
+// LoadScenarios reads scenarios list from json file
+func LoadScenarios(path string) ([]*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Scenario
+	if err = decodeStrict(data, &list); err != nil {
+		return nil, fmt.Errorf("can't decode `%v': %v", path, err)
+	}
+
+	for i, s := range list {
+		if s.Name == "" {
+			return nil, fmt.Errorf("scenario #%v: name is required", i+1)
+		}
+		if s.RUB <= -100 || s.Equity < -100 || s.Dividends < -100 {
+			return nil, fmt.Errorf("scenario `%v': percents must be greater than -100", s.Name)
+		}
+		for c, v := range s.FX {
+			if v <= -100 {
+				return nil, fmt.Errorf("scenario `%v': `%v' change must be greater than -100", s.Name, c)
+			}
+		}
+	}
+
+	return list, nil
+}
+
+// fx returns currency rate multiplier
+func (s *Scenario) fx(currency string) float64 {
+	if isRUB(currency) {
+		return 1
+	}
+	if v, ok := s.FX[currency]; ok {
+		return 1 + v/100
+	}
+
+	return 1 / (1 + s.RUB/100)
+}
+
+// convert converts asset currency value into report currency using shocked rates
+func (s *Scenario) convert(p *Portfolio, a Asset, v float64) float64 {
+	return p.convert(a, v) * s.fx(a.Info().Currency) / s.fx(p.currency)
+}
+
+// StressResult contains portfolio value and yearly income changes under the scenario,
+// loss is positive if the portfolio loses value
+type StressResult struct {
+	Scenario *Scenario `json:"scenario"`
+
+	Value         float64            `json:"value"`
+	StressedValue float64            `json:"stressed_value"`
+	Loss          float64            `json:"loss"`
+	LossPercent   float64            `json:"loss_percent"`
+	Types         map[string]float64 `json:"types"` // loss by asset type
+
+	Income         float64 `json:"income"`
+	StressedIncome float64 `json:"stressed_income"`
+	IncomeChange   float64 `json:"income_change"`
+}
+
+// StressReport contains results of each scenario
+type StressReport struct {
+	Currency string          `json:"currency"`
+	Results  []*StressResult `json:"results"`
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+// currencyByTicker returns currency code of the moex currency ticker
+func currencyByTicker(ticker string) string {
+	for c, t := range moex.CurrencyTickers {
+		if t == ticker {
+			return c
+		}
+	}
+
+	return ""
+}
+
+// Stress revalues assets under the scenarios, prices must be filled: bonds are revalued
+// using duration and convexity of their payments schedules (floating coupons follow
+// the rate shift), currencies using fx rates, stocks and funds using their beta
+func (p *Portfolio) Stress(scenarios []*Scenario, tax float64, today time.Time) *StressReport {
+	var r = &StressReport{Currency: p.Currency()}
+
+	var yields = p.bondYields(tax, today, func(b *Bond, err error) {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("%v: yield is unknown, price is kept: %v", b, err))
+	})
+
+	for _, a := range p.Assets() {
+		if info := a.Info(); info.Type == TypeCurrency && currencyByTicker(info.Ticker) == "" {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("%v: currency is unknown, price is kept", info))
+		}
+	}
+
+	for _, s := range scenarios {
+		var res = &StressResult{Scenario: s, Types: make(map[string]float64)}
+		var shift = s.RateShift / 10000
+
+		for _, a := range p.Assets() {
+			var info = a.Info()
+			var value, income = a.Value(), a.CashFlow(tax)
+
+			switch v := a.(type) {
+			case *Bond:
+				y := yields[a]
+				if y == nil {
+					break
+				}
+
+				value *= 1 + y.risk.PriceChange(shift)
+				if !y.FixedCoupon {
+					income += v.Nominal * v.Count * shift * (1 - tax)
+				}
+			case *Stock, *Fund, *DivFund:
+				var beta = info.Beta
+				if beta == 0 {
+					beta = 1
+				}
+
+				value *= 1 + beta*s.Equity/100
+				if value < 0 {
+					value = 0
+				}
+				if info.Type == TypeStock || info.Type == TypeDivFund {
+					income *= 1 + s.Dividends/100
+				}
+			case *Currency:
+				if c := currencyByTicker(v.Ticker); c != "" {
+					value *= s.fx(c)
+				}
+			}
+
+			var before, after = p.value(a), s.convert(p, a, value)
+
+			res.Value += before
+			res.StressedValue += after
+			res.Types[info.Type] += before - after
+
+			res.Income += p.cashFlow(a, tax)
+			res.StressedIncome += s.convert(p, a, income)
+		}
+
+		res.Loss = res.Value - res.StressedValue
+		if res.Value > 0 {
+			res.LossPercent = res.Loss / res.Value * 100
+		}
+		res.IncomeChange = res.StressedIncome - res.Income
+
+		r.Results = append(r.Results, res)
+	}
+
+	return r
+}
@@ -0,0 +1,180 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spectrec/invest-tools/bond"
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// Candidate is a suitable bond from listing json output (see `-json-output')
+type Candidate struct {
+	SecID             string        `json:"secid"`
+	ISIN              string        `json:"isin"`
+	ShortName         string        `json:"short_name"`
+	Currency          string        `json:"currency"`
+	CleanPricePercent float64       `json:"clean_price_precent"`
+	YieldToMaturity   float64       `json:"yield_to_maturity"` // percent, after tax
+	Duration          float64       `json:"duration"`          // years, till the coupon reset for floaters (as held ones)
+	MaturityDate      time.Time     `json:"maturity_date"`
+	Emitent           *moex.Emitent `json:"emitent"`
+	Rating            string        `json:"rating,omitempty"`
+}
+
+// LoadUniverse reads listing json output
+func LoadUniverse(path string) ([]*Candidate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Candidate
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("can't decode `%v': %v", path, err)
+	}
+
+	return list, nil
+}
+
+// ratingScale contains ratings from the best to the worst one
+var ratingScale = []string{
+	"AAA", "AA+", "AA", "AA-", "A+", "A", "A-",
+	"BBB+", "BBB", "BBB-", "BB+", "BB", "BB-", "B+", "B", "B-",
+	"CCC+", "CCC", "CCC-", "CC", "C", "D",
+}
+
+// ratingRank returns rating position, the better rating the greater rank,
+// unknown rating is 0; national scale marks (`ru', `(RU)', `|ru|') are ignored
+func ratingRank(rating string) int {
+	var r = strings.ToUpper(strings.TrimSpace(rating))
+	r = strings.NewReplacer("А", "A", "В", "B", "С", "C", "(RU)", "", "|RU|", "", ".RU", "").Replace(r)
+	r = strings.TrimPrefix(r, "RU")
+
+	for i, v := range ratingScale {
+		if v == r {
+			return len(ratingScale) - i
+		}
+	}
+
+	return 0
+}
+
+// SwapOptions contains swap costs and candidate restrictions
+type SwapOptions struct {
+	Tax         float64 // fraction
+	Spread      float64 // bid/ask spread lost on both trades, percent of value
+	Commission  float64 // broker commission of each trade, percent
+	DurationGap float64 // max candidate duration difference, years
+	MinGain     float64 // min yield gain after costs, percent points
+}
+
+// Swap is a proposal to replace held bond with the candidate
+type Swap struct {
+	Held       *BondYield `json:"held"`
+	HeldRating string     `json:"held_rating,omitempty"`
+	Candidate  *Candidate `json:"candidate"`
+
+	Costs   float64 `json:"costs"`    // spread, commissions and sale tax in report currency
+	SaleTax float64 `json:"sale_tax"` // part of costs
+	Gain    float64 `json:"gain"`     // yearly after tax yield gain net of costs, percent points
+}
+
+// SwapReport contains the best swap of each held bond
+type SwapReport struct {
+	Currency string   `json:"currency"`
+	Swaps    []*Swap  `json:"swaps"` // sorted by gain
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Swaps looks for candidates of similar duration, equal or better rating and higher after tax
+// yield (net of costs amortized till the earliest maturity) for each held bond, prices must be filled
+func (p *Portfolio) Swaps(universe []*Candidate, opts SwapOptions, today time.Time) *SwapReport {
+	var r = &SwapReport{Currency: p.Currency()}
+
+	p.resolveEmitents()
+
+	var ratings = make(map[string]string) // isin or inn -> rating
+	for _, c := range universe {
+		if c.Rating == "" {
+			continue
+		}
+
+		ratings[c.ISIN] = c.Rating
+		if c.Emitent != nil && c.Emitent.INN != "" {
+			ratings[c.Emitent.INN] = c.Rating
+		}
+	}
+
+	var yields = p.bondYields(opts.Tax, today, func(b *Bond, err error) {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("%v: yield is unknown: %v", b, err))
+	})
+	for _, a := range p.Assets() {
+		var y = yields[a]
+		if y == nil {
+			continue
+		}
+
+		var b = a.(*Bond)
+		var rating = b.Rating
+		if rating == "" {
+			if rating = ratings[b.ISIN]; rating == "" {
+				rating = ratings[b.INN]
+			}
+		}
+		if ratingRank(rating) == 0 {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("%v: rating is unknown, any candidate rating is accepted", b))
+		}
+
+		var value = b.Value()
+		var saleTax float64
+		if b.AvgPrice > 0 && b.Price > b.AvgPrice {
+			saleTax = value * (1 - b.AvgPrice/b.Price) * opts.Tax
+		}
+		var costs = value*(opts.Spread+2*opts.Commission)/100 + saleTax
+
+		var best *Swap
+		for _, c := range universe {
+			if c.ISIN == b.ISIN || isRUB(c.Currency) != isRUB(b.Currency) || (!isRUB(b.Currency) && c.Currency != b.Currency) {
+				continue
+			}
+			if math.Abs(c.Duration-y.Duration) > opts.DurationGap || ratingRank(c.Rating) < ratingRank(rating) {
+				continue
+			}
+
+			var years = math.Min(y.YearsToMaturity, bond.Days(today, c.MaturityDate)/365.0)
+			if years <= 0 || value <= 0 {
+				continue
+			}
+
+			var gain = c.YieldToMaturity - y.NetYield - costs/value*100/years
+			if gain < opts.MinGain || (best != nil && gain <= best.Gain) {
+				continue
+			}
+
+			best = &Swap{
+				Held:       y,
+				HeldRating: rating,
+				Candidate:  c,
+				Costs:      p.convert(b, costs),
+				SaleTax:    p.convert(b, saleTax),
+				Gain:       gain,
+			}
+		}
+
+		if best != nil {
+			r.Swaps = append(r.Swaps, best)
+		}
+	}
+
+	sort.SliceStable(r.Swaps, func(i, j int) bool {
+		return r.Swaps[i].Gain > r.Swaps[j].Gain
+	})
+
+	return r
+}
@@ -0,0 +1,252 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+// long-term ownership exemption: profit of securities held for more than 3 years
+// isn't taxed, the yearly exemption is limited by 3 mln per each full year of holding
+// (years are averaged using proceeds of the sold lots); the limit is common for all
+// the accounts, so the exemption is distributed between companies by gain of their lots
+const (
+	longTermYears = 3
+	longTermLimit = 3000000.0
+)
+
+// Lot is an open FIFO tax lot
+type Lot struct {
+	Company  string    `json:"company"`
+	ID       string    `json:"id"`
+	Date     time.Time `json:"date"`
+	Quantity float64   `json:"quantity"`
+	Cost     float64   `json:"cost"` // purchase amount with fee
+
+	Value      float64 `json:"value"`
+	Unrealized float64 `json:"unrealized"`
+	LongTerm   bool    `json:"long_term"` // exemption is applicable if lot is sold now
+}
+
+// Realized is a closed part of the tax lot
+type Realized struct {
+	Company  string    `json:"company"`
+	ID       string    `json:"id"`
+	BuyDate  time.Time `json:"buy_date"`
+	SellDate time.Time `json:"sell_date"`
+	Quantity float64   `json:"quantity"`
+	Cost     float64   `json:"cost"`
+	Proceeds float64   `json:"proceeds"` // sale amount without fee
+	Gain     float64   `json:"gain"`
+	Exempt   float64   `json:"exempt"` // long-term exemption, share of the yearly one (all companies)
+}
+
+// YearTax contains company income and ndfl estimate of the year
+type YearTax struct {
+	Year    int    `json:"year"`
+	Company string `json:"company"`
+	IIS     bool   `json:"iis"` // tax is due at account closing, exemption isn't applicable
+
+	Gain      float64 `json:"gain"` // realized, losses included
+	Exempt    float64 `json:"exempt"`
+	Fees      float64 `json:"fees"`
+	Coupons   float64 `json:"coupons"`
+	Dividends float64 `json:"dividends"`
+
+	Base float64 `json:"base"`
+	Tax  float64 `json:"tax"`
+}
+
+// TaxReport contains tax lots state and ndfl estimate
+type TaxReport struct {
+	Lots     []*Lot      `json:"lots"`
+	Realized []*Realized `json:"realized"`
+	Years    []*YearTax  `json:"years"`
+
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ndfl returns tax of investment income `base': 13% till the threshold, 15% above it
+func ndfl(year int, base float64) float64 {
+	var threshold = 5000000.0
+	if year >= 2025 {
+		threshold = 2400000.0
+	}
+
+	if base <= threshold {
+		return base * 0.13
+	}
+
+	return threshold*0.13 + (base-threshold)*0.15
+}
+
+// fullYears returns number of full years between `buy' and `sell'
+func fullYears(buy, sell time.Time) int {
+	var n int
+	for !sell.Before(buy.AddDate(n+1, 0, 0)) {
+		n++
+	}
+
+	return n
+}
+
+// isLongTerm checks whether lot bought at `buy' is held for 3 full years at `sell'
+func isLongTerm(buy, sell time.Time) bool {
+	return fullYears(buy, sell) >= longTermYears
+}
+
+// Taxes builds FIFO tax lots and estimates ndfl by year and company, open lots are valued by `value';
+// companies from `iis' are individual investment accounts, their tax is calculated, but not paid yearly
+func (l *Ledger) Taxes(value Valuer, iis map[string]bool, today time.Time) *TaxReport {
+	var r TaxReport
+
+	type yearKey struct {
+		year    int
+		company string
+	}
+	var years = make(map[yearKey]*YearTax)
+	var year = func(t *Transaction) *YearTax {
+		var key = yearKey{t.Date.Year(), t.Company}
+		if years[key] == nil {
+			years[key] = &YearTax{Year: key.year, Company: t.Company, IIS: iis[t.Company]}
+			r.Years = append(r.Years, years[key])
+		}
+
+		return years[key]
+	}
+
+	var longTerm = make(map[int][]*Realized) // by year, lots of all non iis companies
+	var lots = make(map[holdingKey][]*Lot)
+	var order []holdingKey
+	for _, t := range l.Transactions {
+		var key = holdingKey{t.Company, t.ID}
+
+		switch t.Operation {
+		case OpBuy:
+			if lots[key] == nil {
+				order = append(order, key)
+			}
+
+			lots[key] = append(lots[key], &Lot{Company: t.Company, ID: t.ID, Date: t.Date, Quantity: t.Quantity, Cost: t.Amount + t.Fee})
+		case OpSell:
+			var y = year(t)
+			y.Fees += t.Fee
+
+			var left = t.Quantity
+			for left > 0 && len(lots[key]) > 0 {
+				var lot = lots[key][0]
+				var quantity = math.Min(left, lot.Quantity)
+				var cost = lot.Cost * quantity / lot.Quantity
+
+				var sold = &Realized{
+					Company:  t.Company,
+					ID:       t.ID,
+					BuyDate:  lot.Date,
+					SellDate: t.Date,
+					Quantity: quantity,
+					Cost:     cost,
+					Proceeds: t.Amount * quantity / t.Quantity,
+				}
+				sold.Gain = sold.Proceeds - sold.Cost
+
+				if !y.IIS && sold.Gain > 0 && isLongTerm(lot.Date, t.Date) {
+					longTerm[y.Year] = append(longTerm[y.Year], sold)
+				}
+
+				y.Gain += sold.Gain
+				r.Realized = append(r.Realized, sold)
+
+				lot.Cost -= cost
+				lot.Quantity -= quantity
+				if lot.Quantity <= 0 {
+					lots[key] = lots[key][1:]
+				}
+
+				left -= quantity
+			}
+
+			if left > 0 {
+				r.Warnings = append(r.Warnings, fmt.Sprintf("%v: `%v' sells %v of `%v' beyond open lots, their proceeds are ignored",
+					t.Date.Format(moex.DateFormat), t.Company, left, t.ID))
+			}
+		case OpCoupon:
+			year(t).Coupons += t.Amount - t.Fee
+		case OpDividend:
+			year(t).Dividends += t.Amount - t.Fee
+		case OpFee:
+			year(t).Fees += t.Amount + t.Fee
+		}
+	}
+
+	for _, key := range order {
+		for _, lot := range lots[key] {
+			v, ok := value(lot.Company, lot.ID, lot.Quantity)
+			if !ok {
+				v = lot.Cost
+			}
+
+			lot.Value = v
+			lot.Unrealized = v - lot.Cost
+			lot.LongTerm = !iis[lot.Company] && isLongTerm(lot.Date, today)
+
+			r.Lots = append(r.Lots, lot)
+		}
+	}
+
+	// losses of securities reduce profit within the year, coupons and dividends aren't reduced;
+	// progressive rate is applied to the year income of all accounts (iis are summed separately)
+	type baseKey struct {
+		year int
+		iis  bool
+	}
+	for year, realized := range longTerm {
+		exempt(realized)
+		for _, sold := range realized {
+			years[yearKey{year, sold.Company}].Exempt += sold.Exempt
+		}
+	}
+
+	var bases = make(map[baseKey]float64)
+	for _, y := range r.Years {
+		y.Base = math.Max(0, y.Gain-y.Exempt-y.Fees) + y.Coupons + y.Dividends
+		bases[baseKey{y.Year, y.IIS}] += y.Base
+	}
+	for _, y := range r.Years {
+		var total = bases[baseKey{y.Year, y.IIS}]
+		if total > 0 {
+			y.Tax = ndfl(y.Year, total) * y.Base / total
+		}
+	}
+
+	sort.SliceStable(r.Years, func(i, j int) bool {
+		if r.Years[i].Year != r.Years[j].Year {
+			return r.Years[i].Year < r.Years[j].Year
+		}
+
+		return r.Years[i].Company < r.Years[j].Company
+	})
+
+	return &r
+}
+
+// exempt sets long-term exemption of the lots sold within the year: their gain capped
+// by the yearly limit, the exemption is distributed between the lots by their gain
+func exempt(longTerm []*Realized) {
+	var gain, proceeds, years float64
+	for _, sold := range longTerm {
+		gain += sold.Gain
+		proceeds += sold.Proceeds
+		years += sold.Proceeds * float64(fullYears(sold.BuyDate, sold.SellDate))
+	}
+	if gain <= 0 || proceeds <= 0 {
+		return
+	}
+
+	var total = math.Min(gain, longTermLimit*years/proceeds)
+	for _, sold := range longTerm {
+		sold.Exempt = total * sold.Gain / gain
+	}
+}
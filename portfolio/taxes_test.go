@@ -0,0 +1,109 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/spectrec/invest-tools/moex"
+)
+
+func date(s string) time.Time {
+	t, err := moex.ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func equal(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestTaxesFIFO(t *testing.T) {
+	var value = func(company, id string, quantity float64) (float64, bool) {
+		return quantity * 2100, true
+	}
+
+	for _, tt := range []struct {
+		sell   string
+		exempt float64
+		base   float64
+		tax    float64
+	}{
+		// the first lot is held less than 3 full years
+		{sell: "2023-03-01", exempt: 0, base: 125000, tax: 16250},
+		// the first lot gain is exempt, the second one is taxed
+		{sell: "2023-03-02", exempt: 100000, base: 25000, tax: 3250},
+	} {
+		var l = Ledger{Transactions: []*Transaction{
+			{Date: date("2020-03-02"), Company: "broker", Operation: OpBuy, ID: "X", Quantity: 100, Amount: 100000},
+			{Date: date("2021-06-01"), Company: "broker", Operation: OpBuy, ID: "X", Quantity: 100, Amount: 150000},
+			{Date: date(tt.sell), Company: "broker", Operation: OpSell, ID: "X", Quantity: 150, Amount: 300000},
+		}}
+
+		var r = l.Taxes(value, nil, date("2024-10-10"))
+		if len(r.Realized) != 2 || len(r.Lots) != 1 || len(r.Years) != 1 {
+			t.Fatalf("%v: unexpected report: %+v", tt.sell, r)
+		}
+
+		var first, second = r.Realized[0], r.Realized[1]
+		if first.Quantity != 100 || !equal(first.Gain, 100000) || !equal(first.Exempt, tt.exempt) {
+			t.Errorf("%v: first lot is %+v, expected 100 sold with gain 100000 and exempt %v", tt.sell, first, tt.exempt)
+		}
+		if second.Quantity != 50 || !equal(second.Cost, 75000) || !equal(second.Gain, 25000) || second.Exempt != 0 {
+			t.Errorf("%v: second lot is %+v, expected 50 sold with cost 75000 and gain 25000", tt.sell, second)
+		}
+
+		var lot = r.Lots[0]
+		if lot.Quantity != 50 || !equal(lot.Cost, 75000) || !equal(lot.Unrealized, 30000) || !lot.LongTerm {
+			t.Errorf("%v: open lot is %+v, expected 50 of 75000 with unrealized 30000 (long-term)", tt.sell, lot)
+		}
+
+		var y = r.Years[0]
+		if !equal(y.Gain, 125000) || !equal(y.Exempt, tt.exempt) || !equal(y.Base, tt.base) || !equal(y.Tax, tt.tax) {
+			t.Errorf("%v: year is %+v, expected exempt %v, base %v, tax %v", tt.sell, y, tt.exempt, tt.base, tt.tax)
+		}
+	}
+}
+
+func TestTaxesExemptionLimit(t *testing.T) {
+	var l = Ledger{Transactions: []*Transaction{
+		{Date: date("2020-01-10"), Company: "a", Operation: OpBuy, ID: "X", Quantity: 1, Amount: 1000000},
+		{Date: date("2020-01-10"), Company: "b", Operation: OpBuy, ID: "X", Quantity: 1, Amount: 1000000},
+		{Date: date("2020-01-10"), Company: "iis", Operation: OpBuy, ID: "X", Quantity: 1, Amount: 1000000},
+		{Date: date("2023-02-01"), Company: "a", Operation: OpSell, ID: "X", Quantity: 1, Amount: 7000000},
+		{Date: date("2023-02-01"), Company: "b", Operation: OpSell, ID: "X", Quantity: 1, Amount: 7000000},
+		{Date: date("2023-02-01"), Company: "iis", Operation: OpSell, ID: "X", Quantity: 1, Amount: 7000000},
+	}}
+
+	var r = l.Taxes(nil, map[string]bool{"iis": true}, date("2024-10-10"))
+
+	// 3 mln for 3 full years is shared by both brokers, iis isn't exempt
+	for _, tt := range []struct {
+		company string
+		exempt  float64
+		base    float64
+		tax     float64
+	}{
+		{company: "a", exempt: 4500000, base: 1500000, tax: 195000},
+		{company: "b", exempt: 4500000, base: 1500000, tax: 195000},
+		{company: "iis", exempt: 0, base: 6000000, tax: 5000000*0.13 + 1000000*0.15},
+	} {
+		var found bool
+		for _, y := range r.Years {
+			if y.Company != tt.company {
+				continue
+			}
+
+			found = true
+			if !equal(y.Exempt, tt.exempt) || !equal(y.Base, tt.base) || !equal(y.Tax, tt.tax) {
+				t.Errorf("%v: year is %+v, expected exempt %v, base %v, tax %v", tt.company, y, tt.exempt, tt.base, tt.tax)
+			}
+		}
+		if !found {
+			t.Errorf("%v: year isn't found", tt.company)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package portfolio
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// readXLSX returns cell values of all workbook sheets (formulas aren't evaluated,
+// their cached values are used)
+func readXLSX(path string) ([][][]string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var files = make(map[string]*zip.File)
+	var sheets []string
+	for _, f := range r.File {
+		files[f.Name] = f
+		if strings.HasPrefix(f.Name, "xl/worksheets/sheet") && strings.HasSuffix(f.Name, ".xml") {
+			sheets = append(sheets, f.Name)
+		}
+	}
+	sort.Slice(sheets, func(i, j int) bool {
+		return sheetNumber(sheets[i]) < sheetNumber(sheets[j])
+	})
+
+	var strs []string
+	if f := files["xl/sharedStrings.xml"]; f != nil {
+		var sst struct {
+			Items []struct {
+				Text string `xml:"t"`
+				Runs []struct {
+					Text string `xml:"t"`
+				} `xml:"r"`
+			} `xml:"si"`
+		}
+		if err = readZipXML(f, &sst); err != nil {
+			return nil, err
+		}
+
+		for _, si := range sst.Items {
+			var s = si.Text
+			for _, r := range si.Runs {
+				s += r.Text
+			}
+
+			strs = append(strs, s)
+		}
+	}
+
+	var result [][][]string
+	for _, name := range sheets {
+		var sheet struct {
+			Rows []struct {
+				Cells []struct {
+					Ref    string `xml:"r,attr"`
+					Type   string `xml:"t,attr"`
+					Value  string `xml:"v"`
+					Inline string `xml:"is>t"`
+				} `xml:"c"`
+			} `xml:"sheetData>row"`
+		}
+		if err = readZipXML(files[name], &sheet); err != nil {
+			return nil, err
+		}
+
+		var rows [][]string
+		for _, row := range sheet.Rows {
+			var cells []string
+			for i, c := range row.Cells {
+				var col = i
+				if c.Ref != "" {
+					col = columnNumber(c.Ref)
+				}
+				for len(cells) <= col {
+					cells = append(cells, "")
+				}
+
+				switch c.Type {
+				case "s":
+					n, err := strconv.Atoi(c.Value)
+					if err != nil || n < 0 || n >= len(strs) {
+						return nil, fmt.Errorf("%v: invalid shared string `%v' (%v)", name, c.Value, c.Ref)
+					}
+					cells[col] = strs[n]
+				case "inlineStr":
+					cells[col] = c.Inline
+				default:
+					cells[col] = c.Value
+				}
+			}
+
+			rows = append(rows, cells)
+		}
+
+		result = append(result, rows)
+	}
+
+	return result, nil
+}
+
+func readZipXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if err = xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%v: %v", f.Name, err)
+	}
+
+	return nil
+}
+
+// sheetNumber returns number of `xl/worksheets/sheet<N>.xml'
+func sheetNumber(name string) int {
+	n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "xl/worksheets/sheet"), ".xml"))
+	return n
+}
+
+// columnNumber returns zero based column number of `AB12' like cell reference
+func columnNumber(ref string) int {
+	var n int
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+
+		n = n*26 + int(c-'A'+1)
+	}
+
+	return n - 1
+}